@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"net/http"
 	"os/signal"
 	"sync"
 	"syscall"
@@ -10,10 +11,17 @@ import (
 	imagepreviewer "github.com/devgomax/image-previewer/internal/app/image_previewer"
 	"github.com/devgomax/image-previewer/internal/config"
 	"github.com/devgomax/image-previewer/internal/logger"
+	"github.com/devgomax/image-previewer/internal/pkg/breaker"
+	"github.com/devgomax/image-previewer/internal/pkg/bucketing"
+	"github.com/devgomax/image-previewer/internal/pkg/headerpolicy"
+	"github.com/devgomax/image-previewer/internal/pkg/hostfilter"
 	"github.com/devgomax/image-previewer/internal/pkg/lru"
+	"github.com/devgomax/image-previewer/internal/pkg/netguard"
 	"github.com/devgomax/image-previewer/internal/pkg/resizing"
 	internalhttp "github.com/devgomax/image-previewer/internal/server/http"
+	"github.com/devgomax/image-previewer/pkg/urlsign"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
@@ -35,15 +43,76 @@ func main() {
 
 	cache := lru.NewCache(cfg.LRUCacheConfig.Size)
 
-	app := imagepreviewer.NewApp(cache, resizing.NewResizer())
+	guard, err := newGuard(cfg.UpstreamConfig)
+	if err != nil {
+		cancel()
+		log.Fatal().Err(err).Msg("failed to configure upstream network guard")
+	}
+
+	hosts, err := hostfilter.NewFilter(cfg.UpstreamConfig.AllowedHosts, cfg.UpstreamConfig.DeniedHosts)
+	if err != nil {
+		cancel()
+		log.Fatal().Err(err).Msg("failed to configure upstream host filter")
+	}
+
+	headers, err := newHeaderPolicy(cfg.UpstreamConfig.Headers)
+	if err != nil {
+		cancel()
+		log.Fatal().Err(err).Msg("failed to configure upstream header policy")
+	}
+
+	resizerOpts := []resizing.Option{
+		resizing.WithGuard(guard),
+		resizing.WithHostFilter(hosts),
+		resizing.WithHeaderPolicy(headers),
+		resizing.WithRetries(cfg.UpstreamConfig.Retries, cfg.UpstreamConfig.RetryBaseDelay, cfg.UpstreamConfig.RetryMaxDelay),
+		resizing.WithAttemptTimeout(cfg.UpstreamConfig.AttemptTimeout),
+		resizing.WithConcurrency(cfg.UpstreamConfig.Concurrency),
+		resizing.WithMaxPixels(cfg.UpstreamConfig.MaxPixels()),
+		resizing.WithMaxBodySize(cfg.UpstreamConfig.MaxBodySize),
+		resizing.WithOutputLimits(resizing.Limits{
+			Width:  cfg.OutputConfig.MaxWidth,
+			Height: cfg.OutputConfig.MaxHeight,
+			Pixels: cfg.OutputConfig.MaxPixels(),
+		}),
+	}
 
-	r := internalhttp.NewRouter(app,
+	if threshold := cfg.UpstreamConfig.BreakerThreshold; threshold > 0 {
+		resizerOpts = append(resizerOpts,
+			resizing.WithCircuitBreaker(breaker.NewBreaker(threshold, cfg.UpstreamConfig.BreakerTimeout)))
+	}
+
+	resizer := resizing.NewResizer(resizerOpts...)
+
+	bucketer := bucketing.NewBucketer(
+		bucketing.NewAxis(cfg.SizeBuckets.Widths, cfg.SizeBuckets.WidthStep),
+		bucketing.NewAxis(cfg.SizeBuckets.Heights, cfg.SizeBuckets.HeightStep),
+		cfg.SizeBuckets.Strict,
+	)
+
+	app := imagepreviewer.NewApp(cache, resizer,
+		imagepreviewer.WithSizeBuckets(bucketer),
+	)
+
+	middlewares := []func(http.Handler) http.Handler{
 		middleware.RequestID,
 		middleware.RealIP,
 		middleware.Logger,
 		middleware.Recoverer,
-		middleware.Timeout(20*time.Second),
-	)
+		middleware.Timeout(20 * time.Second),
+	}
+
+	if keys := cfg.Signature.Keys; len(keys) > 0 {
+		signer, err := newSigner(keys)
+		if err != nil {
+			cancel()
+			log.Fatal().Err(err).Msg("failed to configure request signature")
+		}
+
+		middlewares = append(middlewares, internalhttp.SignatureMiddleware(signer))
+	}
+
+	r := internalhttp.NewRouter(app, middlewares...)
 
 	server := internalhttp.NewServer(cfg.HTTPConfig.GetAddr(), r)
 
@@ -70,3 +139,56 @@ func main() {
 
 	wg.Wait()
 }
+
+// newGuard creates the guard of upstream connections from the config.
+func newGuard(cfg config.UpstreamConfig) (*netguard.Guard, error) {
+	blocked, err := netguard.ParsePrefixes(cfg.BlockedNetworks)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := netguard.ParsePrefixes(cfg.AllowedNetworks)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []netguard.Option{netguard.WithBlocked(blocked...), netguard.WithAllowed(allowed...)}
+	if cfg.AllowPrivateNetworks {
+		opts = append(opts, netguard.WithPrivateNetworks())
+	}
+
+	return netguard.NewGuard(opts...), nil
+}
+
+// newHeaderPolicy creates the policy of forwarding client headers to upstreams from the config.
+func newHeaderPolicy(cfg config.HeadersConfig) (*headerpolicy.Policy, error) {
+	p := headerpolicy.NewPolicy(headerRules(cfg.HeaderRulesConfig))
+
+	for _, o := range cfg.Origins {
+		if err := p.Override(o.Host, headerRules(o.HeaderRulesConfig)); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func headerRules(cfg config.HeaderRulesConfig) headerpolicy.Rules {
+	return headerpolicy.Rules{Allow: cfg.Allow, Deny: cfg.Deny, Set: cfg.Set}
+}
+
+// newSigner creates the signer verifying request URLs with the configured keys. Weak keys are rejected.
+func newSigner(keys []string) (*urlsign.Signer, error) {
+	for i, key := range keys {
+		if err := urlsign.CheckKey([]byte(key)); err != nil {
+			return nil, errors.Wrapf(err, "signature key #%d", i+1)
+		}
+	}
+
+	previous := make([][]byte, 0, len(keys)-1)
+	for _, key := range keys[1:] {
+		previous = append(previous, []byte(key))
+	}
+
+	return urlsign.NewSigner([]byte(keys[0]), previous...), nil
+}
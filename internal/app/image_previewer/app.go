@@ -1,20 +1,39 @@
 package imagepreviewer
 
 import (
+	"github.com/devgomax/image-previewer/internal/pkg/bucketing"
 	"github.com/devgomax/image-previewer/internal/pkg/lru"
 	"github.com/devgomax/image-previewer/internal/pkg/resizing"
 )
 
 // App represents the main application logic for the image previewer. It includes caching and resizing functionalities.
 type App struct {
-	cache   lru.ICache
-	resizer *resizing.Resizer
+	cache    lru.ICache
+	resizer  *resizing.Resizer
+	bucketer *bucketing.Bucketer
+}
+
+// Option configures an App.
+type Option func(a *App)
+
+// WithSizeBuckets makes the App round requested preview sizes to buckets before the cache lookup.
+func WithSizeBuckets(bucketer *bucketing.Bucketer) Option {
+	return func(a *App) {
+		a.bucketer = bucketer
+	}
 }
 
 // NewApp creates a new instance of the App with specified caching and resizing configurations.
-func NewApp(cache lru.ICache, resizer *resizing.Resizer) *App {
-	return &App{
-		cache:   cache,
-		resizer: resizer,
+func NewApp(cache lru.ICache, resizer *resizing.Resizer, opts ...Option) *App {
+	a := &App{
+		cache:    cache,
+		resizer:  resizer,
+		bucketer: bucketing.NewBucketer(bucketing.Axis{}, bucketing.Axis{}, false),
 	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
 }
@@ -0,0 +1,213 @@
+package imagepreviewer
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/devgomax/image-previewer/internal/pkg/encoding"
+	"github.com/devgomax/image-previewer/internal/pkg/resizing"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxRequestBodySize is the maximum size of JSON request bodies.
+	maxRequestBodySize = 1 << 20
+
+	// maxRenditions is the maximum number of renditions of a single batch request.
+	maxRenditions = 32
+)
+
+// rendition describes a single output of the batch request.
+type rendition struct {
+	Width   uint   `json:"width"`
+	Height  uint   `json:"height"`
+	Format  string `json:"format,omitempty"`
+	KeepICC bool   `json:"keep_icc,omitempty"`
+	Quality int    `json:"quality,omitempty"`
+}
+
+// batchRequest is the request body of the batch request.
+type batchRequest struct {
+	URL        string      `json:"url"`
+	Renditions []rendition `json:"renditions"`
+}
+
+// batchSource describes the source image in the batch manifest.
+type batchSource struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Format string `json:"format"`
+}
+
+// batchItem is a single rendition of the batch manifest.
+type batchItem struct {
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Format      string `json:"format"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data,omitempty"`
+}
+
+// batchManifest is the response body of the batch request.
+type batchManifest struct {
+	Source     batchSource `json:"source"`
+	Renditions []batchItem `json:"renditions"`
+}
+
+// Batch handles the batch request.
+// It fetches and decodes the source image once and renders every requested rendition of it.
+// The response is a JSON manifest with base64 encoded renditions, or a multipart/mixed body
+// with the manifest followed by one part per rendition if the client accepts it.
+func (a *App) Batch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Batch]: failed to decode request body")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	imageURL, err := url.Parse(req.URL)
+	if err != nil || req.URL == "" {
+		log.Error().Err(err).Msg("[image_previewer::Batch]: invalid source url")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Renditions) == 0 || len(req.Renditions) > maxRenditions {
+		log.Error().Int("renditions", len(req.Renditions)).Msg("[image_previewer::Batch]: invalid number of renditions")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	for _, rd := range req.Renditions {
+		if (rd.Format != "" && !isOutputFormat(rd.Format)) || rd.Quality < 0 || rd.Quality > 100 {
+			log.Error().Str("format", rd.Format).Int("quality", rd.Quality).Msg("[image_previewer::Batch]: invalid rendition")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		// Dimensions derived from the source are checked once it's decoded.
+		size := resizing.Size{Width: resizing.Dimension{Value: rd.Width}, Height: resizing.Dimension{Value: rd.Height}}
+		if rd.Width > maxCanvasSize || rd.Height > maxCanvasSize || a.resizer.CheckSize(r.Context(), size) != nil {
+			log.Error().Uint("width", rd.Width).Uint("height", rd.Height).Msg("[image_previewer::Batch]: rendition exceeds the limits")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	if slices.ContainsFunc(req.Renditions, func(rd rendition) bool { return rd.KeepICC }) {
+		ctx = resizing.NewICCContext(ctx)
+	}
+
+	src, err := a.resizer.FetchImage(ctx, imageURL.String(), r.Header)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Batch]: failed to fetch image")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	manifest := batchManifest{
+		Source: batchSource{
+			Width:  src.Image.Bounds().Dx(),
+			Height: src.Image.Bounds().Dy(),
+			Format: src.Format,
+		},
+		Renditions: make([]batchItem, 0, len(req.Renditions)),
+	}
+
+	for _, rd := range req.Renditions {
+		if err = a.resizer.CheckResize(r.Context(), rd.Width, rd.Height, src.Image.Bounds().Size()); err != nil {
+			log.Error().Err(err).Msg("[image_previewer::Batch]: rendition exceeds the limits")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	for _, rd := range req.Renditions {
+		item, err := renderRendition(src, rd)
+		if err != nil {
+			log.Error().Err(err).Msg("[image_previewer::Batch]: failed to render rendition")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		manifest.Renditions = append(manifest.Renditions, item)
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "multipart/mixed") {
+		writeBatchMultipart(w, manifest)
+		return
+	}
+
+	writeJSON(w, manifest)
+}
+
+// renderRendition resizes and encodes the source image as described by the rendition.
+func renderRendition(src *resizing.Image, rd rendition) (batchItem, error) {
+	resized := resizing.Resize(src, rd.Width, rd.Height)
+
+	output := outputOptions{format: rd.Format, keepICC: rd.KeepICC, quality: rd.Quality}
+
+	data, format, err := newCacheValue(resized).encode(output)
+	if err != nil {
+		return batchItem{}, errors.Wrap(err, "[image_previewer::renderRendition]")
+	}
+
+	return batchItem{
+		Width:       resized.Image.Bounds().Dx(),
+		Height:      resized.Image.Bounds().Dy(),
+		Format:      format,
+		ContentType: encoding.ContentType(format),
+		Data:        data,
+	}, nil
+}
+
+// writeBatchMultipart writes the batch manifest without inline data as the first part
+// and every rendition as a separate part of a multipart/mixed response.
+func writeBatchMultipart(w http.ResponseWriter, manifest batchManifest) {
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+
+	items := manifest.Renditions
+	manifest.Renditions = make([]batchItem, 0, len(items))
+	for _, item := range items {
+		item.Data = nil
+		manifest.Renditions = append(manifest.Renditions, item)
+	}
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json"}})
+	if err == nil {
+		err = json.NewEncoder(part).Encode(manifest)
+	}
+
+	for i, item := range items {
+		if err != nil {
+			break
+		}
+
+		part, err = mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":        {item.ContentType},
+			"Content-Disposition": {fmt.Sprintf(`attachment; filename="rendition-%d.%s"`, i, item.Format)},
+		})
+		if err == nil {
+			_, err = part.Write(item.Data)
+		}
+	}
+
+	if err == nil {
+		err = mw.Close()
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::writeBatchMultipart]: failed to write response body")
+	}
+}
@@ -0,0 +1,137 @@
+package imagepreviewer
+
+import (
+	"image"
+	"net/http"
+	"net/url"
+
+	"github.com/devgomax/image-previewer/internal/pkg/collage"
+	"github.com/devgomax/image-previewer/internal/pkg/colors"
+	"github.com/devgomax/image-previewer/internal/pkg/encoding"
+	"github.com/devgomax/image-previewer/internal/pkg/resizing"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	maxCollageImages = 64
+	maxCanvasSize    = 8192
+)
+
+// collageRequest is the request body of the collage request.
+type collageRequest struct {
+	URLs       []string `json:"urls"`
+	Columns    int      `json:"columns"`
+	CellWidth  int      `json:"cell_width"`
+	CellHeight int      `json:"cell_height"`
+	Gap        int      `json:"gap"`
+	Background string   `json:"background"`
+	Fit        string   `json:"fit"`
+	Format     string   `json:"format"`
+}
+
+// Collage handles the collage request.
+// It fetches the source images concurrently and composes them into a single image laid out as a grid.
+func (a *App) Collage(w http.ResponseWriter, r *http.Request) {
+	req := collageRequest{
+		Background: "#ffffff",
+		Fit:        collage.FitCover,
+		Format:     "jpeg",
+	}
+
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Collage]: failed to decode request body")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if len(req.URLs) == 0 || len(req.URLs) > maxCollageImages {
+		log.Error().Int("urls", len(req.URLs)).Msg("[image_previewer::Collage]: invalid number of images")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	urls := make([]string, 0, len(req.URLs))
+	for _, u := range req.URLs {
+		imageURL, err := url.Parse(u)
+		if err != nil || u == "" {
+			log.Error().Err(err).Str("url", u).Msg("[image_previewer::Collage]: invalid source url")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		urls = append(urls, imageURL.String())
+	}
+
+	background, err := colors.ParseHex(req.Background)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Collage]: invalid background")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	layout := collage.Layout{
+		Columns:    req.Columns,
+		CellWidth:  req.CellWidth,
+		CellHeight: req.CellHeight,
+		Gap:        req.Gap,
+		Background: background,
+		Fit:        req.Fit,
+	}
+
+	if err = layout.Validate(); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Collage]: invalid layout")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	size := layout.Size(len(urls))
+	if size.X > maxCanvasSize || size.Y > maxCanvasSize {
+		log.Error().Int("width", size.X).Int("height", size.Y).Msg("[image_previewer::Collage]: canvas is too large")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err = a.resizer.CheckOutput(r.Context(), size); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Collage]: canvas exceeds the limits")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if req.Format != "jpeg" && req.Format != "png" {
+		log.Error().Str("format", req.Format).Msg("[image_previewer::Collage]: unsupported format")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	// Sources are fitted into the cells as soon as they are fetched, so the decoded sources aren't kept.
+	cell := image.Pt(layout.CellWidth, layout.CellHeight)
+	sources, err := a.resizer.FetchImages(r.Context(), urls, r.Header, func(_ int, img *resizing.Image) *resizing.Image {
+		return &resizing.Image{Image: collage.Fit(img.Image, cell, layout.Fit), Format: img.Format}
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Collage]: failed to fetch images")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	images := make([]image.Image, 0, len(sources))
+	for _, src := range sources {
+		images = append(images, src.Image)
+	}
+
+	canvas, err := collage.Compose(images, layout)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Collage]: failed to compose collage")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := encoding.Encode(canvas, req.Format, encoding.Options{})
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Collage]: failed to encode collage")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	cachedResponse{contentType: encoding.ContentType(req.Format), body: data}.write(w)
+}
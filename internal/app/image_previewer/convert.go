@@ -0,0 +1,76 @@
+package imagepreviewer
+
+import (
+	"net/http"
+
+	"github.com/devgomax/image-previewer/internal/pkg/encoding"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// ConvertImage handles the format conversion request.
+// It re-encodes the image in the format from the URL parameter at its original dimensions.
+// It accepts the same keep_icc and quality query parameters as PreviewImage. Conversion to the source format
+// without quality passes the upstream body through.
+func (a *App) ConvertImage(w http.ResponseWriter, r *http.Request) {
+	format := chi.URLParam(r, "format")
+	if !isOutputFormat(format) {
+		log.Error().Str("format", format).Msg("[image_previewer::ConvertImage]: unsupported format")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	imageURL, err := parseImageURL(r)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::ConvertImage]: failed to parse imageurl")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	output, err := parseOutputOptions(r)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::ConvertImage]: failed to parse output options")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	output.format = format
+
+	if err = a.resizer.CheckSource(r.Context(), imageURL); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::ConvertImage]: source is not allowed")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	key := getCacheKeyForSource(imageURL.String(), output.keepICC)
+
+	val, _ := a.cache.Get(key)
+	cacheVal, ok := val.(cacheValue)
+	if !ok {
+		src, err := a.resizer.FetchImage(outputContext(r, output), imageURL.String(), r.Header)
+		if err != nil {
+			log.Error().Err(err).Msg("[image_previewer::ConvertImage]: failed to fetch image")
+			status := upstreamErrorStatus(err)
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		cacheVal = newCacheValue(src)
+		a.cache.Set(key, cacheVal)
+	}
+
+	if err = a.resizer.CheckOutput(r.Context(), cacheVal.img.Bounds().Size()); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::ConvertImage]: image exceeds the limits")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	data, format, err := cacheVal.encode(output)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::ConvertImage]: failed to encode image")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	cachedResponse{contentType: encoding.ContentType(format), body: data}.write(w)
+}
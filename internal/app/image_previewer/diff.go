@@ -0,0 +1,151 @@
+package imagepreviewer
+
+import (
+	"image"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/devgomax/image-previewer/internal/pkg/encoding"
+	"github.com/devgomax/image-previewer/internal/pkg/similarity"
+	"github.com/nfnt/resize"
+	"github.com/rs/zerolog/log"
+)
+
+// maxDiffSize is the maximum side of the common size images are compared at.
+const maxDiffSize = 1024
+
+// imageDiff is the response body of the image diff request. PSNR is null for identical images.
+type imageDiff struct {
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	SSIM   float64  `json:"ssim"`
+	PSNR   *float64 `json:"psnr"`
+}
+
+// DiffImages handles the image diff request.
+// It resizes the images from the a and b query parameters to a common size and responds with their SSIM
+// and PSNR scores. With visual=true it responds with a PNG highlighting the changed regions instead,
+// the scores are passed in the X-SSIM and X-PSNR headers. X-PSNR is omitted for identical images.
+func (a *App) DiffImages(w http.ResponseWriter, r *http.Request) {
+	urlA, err := parseURLQuery(r, "a")
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DiffImages]: invalid query param a")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	urlB, err := parseURLQuery(r, "b")
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DiffImages]: invalid query param b")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	width, err := parseIntQuery(r, "width", 0)
+	if err != nil || width < 0 || width > maxDiffSize {
+		log.Error().Err(err).Int("width", width).Msg("[image_previewer::DiffImages]: invalid query param width")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	height, err := parseIntQuery(r, "height", 0)
+	if err != nil || height < 0 || height > maxDiffSize {
+		log.Error().Err(err).Int("height", height).Msg("[image_previewer::DiffImages]: invalid query param height")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	visual, err := parseBoolQuery(r, "visual")
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DiffImages]: failed to parse query param visual")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	imgA, err := a.resizer.FetchImage(r.Context(), urlA.String(), r.Header)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DiffImages]: failed to fetch image a")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	imgB, err := a.resizer.FetchImage(r.Context(), urlB.String(), r.Header)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DiffImages]: failed to fetch image b")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	// Missing dimensions are derived from the aspect ratio of the first image fitted into the maximum diff size.
+	bounds := imgA.Image.Bounds()
+	switch {
+	case width == 0 && height == 0:
+		width, height = bounds.Dx(), bounds.Dy()
+		if scale := float64(maxDiffSize) / float64(max(width, height)); scale < 1 {
+			width, height = max(int(float64(width)*scale), 1), max(int(float64(height)*scale), 1)
+		}
+	case width == 0:
+		width = min(max(height*bounds.Dx()/bounds.Dy(), 1), maxDiffSize)
+	case height == 0:
+		height = min(max(width*bounds.Dy()/bounds.Dx(), 1), maxDiffSize)
+	}
+
+	if visual {
+		if err = a.resizer.CheckOutput(r.Context(), image.Pt(width, height)); err != nil {
+			log.Error().Err(err).Msg("[image_previewer::DiffImages]: diff exceeds the limits")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	resizedA := resize.Resize(uint(width), uint(height), imgA.Image, resize.Lanczos3) //nolint:gosec
+	resizedB := resize.Resize(uint(width), uint(height), imgB.Image, resize.Lanczos3) //nolint:gosec
+
+	ssim, err := similarity.SSIM(resizedA, resizedB)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DiffImages]: failed to compute ssim")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	psnr, err := similarity.PSNR(resizedA, resizedB)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DiffImages]: failed to compute psnr")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := imageDiff{Width: width, Height: height, SSIM: ssim}
+	if !math.IsInf(psnr, 1) {
+		resp.PSNR = &psnr
+	}
+
+	if !visual {
+		writeJSON(w, resp)
+		return
+	}
+
+	diff, err := similarity.Diff(resizedA, resizedB)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DiffImages]: failed to render diff")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := encoding.Encode(diff, "png", encoding.Options{})
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DiffImages]: failed to encode diff")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-SSIM", strconv.FormatFloat(ssim, 'f', 6, 64))
+	if resp.PSNR != nil {
+		w.Header().Set("X-PSNR", strconv.FormatFloat(*resp.PSNR, 'f', 6, 64))
+	}
+
+	cachedResponse{contentType: encoding.ContentType("png"), body: data}.write(w)
+}
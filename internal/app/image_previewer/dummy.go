@@ -0,0 +1,106 @@
+package imagepreviewer
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/devgomax/image-previewer/internal/pkg/colors"
+	"github.com/devgomax/image-previewer/internal/pkg/dummy"
+	"github.com/devgomax/image-previewer/internal/pkg/encoding"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultDummyBackground = "#cccccc"
+	defaultDummyForeground = "#333333"
+
+	// maxDummyText is the maximum number of characters in the text of a dummy image.
+	maxDummyText = 256
+)
+
+// DummyImage handles the synthetic placeholder request.
+// It renders an image of the requested size with centered text without fetching anything from upstream.
+// The text defaults to the image dimensions.
+func (a *App) DummyImage(w http.ResponseWriter, r *http.Request) {
+	width, err := strconv.Atoi(chi.URLParam(r, "width"))
+	if err != nil || width < 1 || width > maxCanvasSize {
+		log.Error().Err(err).Msg("[image_previewer::DummyImage]: invalid urlparam width")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	height, err := strconv.Atoi(chi.URLParam(r, "height"))
+	if err != nil || height < 1 || height > maxCanvasSize {
+		log.Error().Err(err).Msg("[image_previewer::DummyImage]: invalid urlparam height")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err = a.resizer.CheckOutput(r.Context(), image.Pt(width, height)); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DummyImage]: size exceeds the limits")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+
+	bg, err := colors.ParseHex(cmp.Or(query.Get("bg"), defaultDummyBackground))
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DummyImage]: invalid query param bg")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	fg, err := colors.ParseHex(cmp.Or(query.Get("fg"), defaultDummyForeground))
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DummyImage]: invalid query param fg")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	text := fmt.Sprintf("%dx%d", width, height)
+	if query.Has("text") {
+		text = query.Get("text")
+	}
+
+	if utf8.RuneCountInString(text) > maxDummyText {
+		log.Error().Msg("[image_previewer::DummyImage]: query param text is too long")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	format := cmp.Or(query.Get("format"), "png")
+	if format != "png" && format != "jpeg" {
+		log.Error().Str("format", format).Msg("[image_previewer::DummyImage]: unsupported format")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	img, err := dummy.Render(dummy.Options{
+		Width:      width,
+		Height:     height,
+		Background: bg,
+		Foreground: fg,
+		Text:       text,
+		Pattern:    query.Get("pattern"),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DummyImage]: failed to render image")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	data, err := encoding.Encode(img, format, encoding.Options{})
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::DummyImage]: failed to encode image")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	cachedResponse{contentType: encoding.ContentType(format), body: data}.write(w)
+}
@@ -0,0 +1,106 @@
+package imagepreviewer
+
+import (
+	"net/http"
+
+	"github.com/devgomax/image-previewer/internal/pkg/phash"
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// imageHash is the response body of the image hash request.
+type imageHash struct {
+	Algo string `json:"algo"`
+	Hash string `json:"hash"`
+}
+
+// hashComparison is the response body of the image comparison request.
+type hashComparison struct {
+	Algo     string `json:"algo"`
+	A        string `json:"a"`
+	B        string `json:"b"`
+	Distance int    `json:"distance"`
+}
+
+// ImageHash handles the perceptual hash request.
+// It responds with the hash of the image computed by the algorithm from the algo parameter.
+func (a *App) ImageHash(w http.ResponseWriter, r *http.Request) {
+	algo := chi.URLParam(r, "algo")
+
+	imageURL, err := parseImageURL(r)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::ImageHash]: failed to parse imageurl")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	hash, status, err := a.computeHash(r, algo, imageURL.String())
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::ImageHash]: failed to compute image hash")
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	writeJSON(w, imageHash{Algo: algo, Hash: hash.String()})
+}
+
+// CompareImages handles the image comparison request.
+// It computes the hashes of the images from the a and b query parameters and responds with their Hamming distance.
+func (a *App) CompareImages(w http.ResponseWriter, r *http.Request) {
+	algo := chi.URLParam(r, "algo")
+
+	urlA, err := parseURLQuery(r, "a")
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::CompareImages]: invalid query param a")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	urlB, err := parseURLQuery(r, "b")
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::CompareImages]: invalid query param b")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	hashA, status, err := a.computeHash(r, algo, urlA.String())
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::CompareImages]: failed to compute hash of image a")
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	hashB, status, err := a.computeHash(r, algo, urlB.String())
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::CompareImages]: failed to compute hash of image b")
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	writeJSON(w, hashComparison{
+		Algo:     algo,
+		A:        hashA.String(),
+		B:        hashB.String(),
+		Distance: phash.Distance(hashA, hashB),
+	})
+}
+
+// computeHash fetches an image and computes its hash. On failure it also returns the HTTP status to respond with.
+func (a *App) computeHash(r *http.Request, algo, imageURL string) (phash.Hash, int, error) {
+	if !phash.Supported(algo) {
+		return 0, http.StatusNotFound, errors.Wrapf(phash.ErrUnknownAlgorithm, "%q", algo)
+	}
+
+	img, err := a.resizer.FetchImage(r.Context(), imageURL, r.Header)
+	if err != nil {
+		return 0, upstreamErrorStatus(err), err
+	}
+
+	hash, err := phash.Compute(algo, img.Image)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+
+	return hash, http.StatusOK, nil
+}
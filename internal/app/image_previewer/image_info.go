@@ -0,0 +1,74 @@
+package imagepreviewer
+
+import (
+	"net/http"
+
+	"github.com/devgomax/image-previewer/internal/pkg/colors"
+	"github.com/devgomax/image-previewer/internal/pkg/metadata"
+	"github.com/devgomax/image-previewer/internal/pkg/resizing"
+	"github.com/rs/zerolog/log"
+)
+
+// imageInfo is the response body of the image info request.
+type imageInfo struct {
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+	Format        string `json:"format"`
+	ColorModel    string `json:"color_model"`
+	ByteSize      int    `json:"byte_size"`
+	HasAlpha      bool   `json:"has_alpha"`
+	FrameCount    int    `json:"frame_count"`
+	Orientation   int    `json:"orientation"`
+	DominantColor string `json:"dominant_color"`
+}
+
+// ImageInfo handles the image info request.
+// It fetches the image from the URL parameter and responds with its metadata as JSON.
+func (a *App) ImageInfo(w http.ResponseWriter, r *http.Request) {
+	imageURL, err := parseImageURL(r)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::ImageInfo]: failed to parse imageurl")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	data, err := a.resizer.Fetch(r.Context(), imageURL.String(), r.Header)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::ImageInfo]: failed to fetch image")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	cfg, format, err := resizing.DecodeConfig(data)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::ImageInfo]: failed to decode image config")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	info := imageInfo{
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		Format:      format,
+		ColorModel:  colors.ModelName(cfg.ColorModel),
+		ByteSize:    len(data),
+		FrameCount:  metadata.FrameCount(data, format),
+		Orientation: metadata.Orientation(data, format),
+	}
+
+	// Alpha and dominant color depend on pixel data, so they are the only fields requiring a full decode.
+	img, err := a.resizer.Decode(data)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::ImageInfo]: failed to decode image")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	info.HasAlpha = colors.HasAlpha(img.Image)
+	info.DominantColor = colors.Hex(colors.Dominant(img.Image))
+
+	writeJSON(w, info)
+}
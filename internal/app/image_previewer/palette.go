@@ -0,0 +1,59 @@
+package imagepreviewer
+
+import (
+	"net/http"
+
+	"github.com/devgomax/image-previewer/internal/pkg/colors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultPaletteSize = 5
+	maxPaletteSize     = 32
+)
+
+// paletteColor is a single color of the palette response.
+type paletteColor struct {
+	Hex        string  `json:"hex"`
+	Population float64 `json:"population"`
+}
+
+// palette is the response body of the palette request.
+type palette struct {
+	Colors []paletteColor `json:"colors"`
+}
+
+// Palette handles the palette request.
+// It responds with up to n dominant colors of the image as hex values with the share of pixels they represent.
+func (a *App) Palette(w http.ResponseWriter, r *http.Request) {
+	imageURL, err := parseImageURL(r)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Palette]: failed to parse imageurl")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	n, err := parseIntQuery(r, "n", defaultPaletteSize)
+	if err != nil || n < 1 || n > maxPaletteSize {
+		log.Error().Err(err).Int("n", n).Msg("[image_previewer::Palette]: invalid query param n")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	img, err := a.resizer.FetchImage(r.Context(), imageURL.String(), r.Header)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Palette]: failed to fetch image")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	swatches := colors.Palette(img.Image, n)
+
+	resp := palette{Colors: make([]paletteColor, 0, len(swatches))}
+	for _, s := range swatches {
+		resp.Colors = append(resp.Colors, paletteColor{Hex: colors.Hex(s.Color), Population: s.Population})
+	}
+
+	writeJSON(w, resp)
+}
@@ -0,0 +1,140 @@
+package imagepreviewer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/devgomax/image-previewer/internal/pkg/blurhash"
+	"github.com/devgomax/image-previewer/internal/pkg/colors"
+	"github.com/devgomax/image-previewer/internal/pkg/encoding"
+	"github.com/devgomax/image-previewer/internal/pkg/resizing"
+	"github.com/go-chi/chi/v5"
+	"github.com/nfnt/resize"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	placeholderBlurHash = "blurhash"
+	placeholderLQIP     = "lqip"
+	placeholderSVG      = "svg"
+
+	defaultBlurHashX      = 4
+	defaultBlurHashY      = 3
+	maxBlurHashComponents = 9
+	defaultLQIPSize       = 16
+	maxLQIPSize           = 64
+)
+
+// Placeholder handles the low-quality placeholder request.
+// Depending on the kind parameter it responds with a BlurHash string, a tiny JPEG as a base64 data URI
+// or an SVG filled with the dominant color of the image.
+func (a *App) Placeholder(w http.ResponseWriter, r *http.Request) {
+	kind := chi.URLParam(r, "kind")
+	if kind != placeholderBlurHash && kind != placeholderLQIP && kind != placeholderSVG {
+		log.Error().Str("kind", kind).Msg("[image_previewer::Placeholder]: unsupported placeholder kind")
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	imageURL, err := parseImageURL(r)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Placeholder]: failed to parse imageurl")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	// Only the params of the requested kind are parsed, the other ones are ignored and don't affect the cache key.
+	var xComponents, yComponents, size int
+
+	switch kind {
+	case placeholderBlurHash:
+		xComponents, err = parseIntQuery(r, "x", defaultBlurHashX)
+		if err != nil || xComponents < 1 || xComponents > maxBlurHashComponents {
+			log.Error().Err(err).Int("x", xComponents).Msg("[image_previewer::Placeholder]: invalid query param x")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		yComponents, err = parseIntQuery(r, "y", defaultBlurHashY)
+		if err != nil || yComponents < 1 || yComponents > maxBlurHashComponents {
+			log.Error().Err(err).Int("y", yComponents).Msg("[image_previewer::Placeholder]: invalid query param y")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	case placeholderLQIP:
+		size, err = parseIntQuery(r, "size", defaultLQIPSize)
+		if err != nil || size < 1 || size > maxLQIPSize {
+			log.Error().Err(err).Int("size", size).Msg("[image_previewer::Placeholder]: invalid query param size")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err = a.resizer.CheckSource(r.Context(), imageURL); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Placeholder]: source is not allowed")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	key := getCacheKeyForPlaceholder(imageURL.String(), kind, xComponents, yComponents, size)
+	if val, ok := a.cache.Get(key); ok {
+		if resp, ok := val.(cachedResponse); ok {
+			resp.write(w)
+			return
+		}
+	}
+
+	img, err := a.resizer.FetchImage(r.Context(), imageURL.String(), r.Header)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Placeholder]: failed to fetch image")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	var resp cachedResponse
+
+	switch kind {
+	case placeholderBlurHash:
+		hash, err := blurhash.Encode(xComponents, yComponents, img.Image)
+		if err != nil {
+			log.Error().Err(err).Msg("[image_previewer::Placeholder]: failed to compute blurhash")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		resp = cachedResponse{contentType: "text/plain; charset=utf-8", body: []byte(hash)}
+	case placeholderLQIP:
+		uri, err := lqipDataURI(img, size)
+		if err != nil {
+			log.Error().Err(err).Msg("[image_previewer::Placeholder]: failed to encode lqip")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		resp = cachedResponse{contentType: "text/plain; charset=utf-8", body: []byte(uri)}
+	case placeholderSVG:
+		b := img.Image.Bounds()
+		svg := fmt.Sprintf(
+			`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+
+				`<rect width="100%%" height="100%%" fill="%s"/></svg>`,
+			b.Dx(), b.Dy(), b.Dx(), b.Dy(), colors.Hex(colors.Dominant(img.Image)),
+		)
+		resp = cachedResponse{contentType: "image/svg+xml", body: []byte(svg)}
+	}
+
+	a.cache.Set(key, resp)
+	resp.write(w)
+}
+
+// lqipDataURI downscales an image to fit in a size x size box and returns it as a base64 JPEG data URI.
+func lqipDataURI(img *resizing.Image, size int) (string, error) {
+	thumb := resize.Thumbnail(uint(size), uint(size), img.Image, resize.Bilinear) //nolint:gosec
+
+	data, err := encoding.Encode(thumb, "jpeg", encoding.Options{})
+	if err != nil {
+		return "", err
+	}
+
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -1,93 +1,137 @@
 package imagepreviewer
 
 import (
-	"bytes"
-	"image"
-	"image/jpeg"
-	"image/png"
 	"net/http"
-	"net/url"
-	"strconv"
 
+	"github.com/devgomax/image-previewer/internal/pkg/encoding"
+	"github.com/devgomax/image-previewer/internal/pkg/resizing"
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog/log"
 )
 
 // PreviewImage handles the preview image request.
 // It takes a URL parameter for the image and two additional parameters for the width and height of the preview.
+// Dimensions are in pixels or in percent of the source with the "p" suffix, the ar query parameter
+// derives the missing dimension from the aspect ratio.
+// Source metadata is stripped from the preview unless the keep_icc query parameter asks to keep the ICC profile,
+// the quality query parameter sets the JPEG quality and the format query parameter overrides the source format,
+// format=smart picks it by the image content. If the requested size matches the source and no encoding change
+// is requested, the upstream body is served as is apart from the stripped metadata.
 func (a *App) PreviewImage(w http.ResponseWriter, r *http.Request) {
 	var (
-		format  string
-		resized image.Image
-		err     error
+		cacheVal cacheValue
+		ok       bool
+		err      error
 	)
 
-	widthParam := chi.URLParam(r, "width")
-	width, err := strconv.ParseUint(widthParam, 10, 32)
+	width, err := parseDimension(chi.URLParam(r, "width"))
 	if err != nil {
 		log.Error().Err(err).Msg("[image_previewer::PreviewImage]: failed to parse urlparam width")
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
-	heightParam := chi.URLParam(r, "height")
-	height, err := strconv.ParseUint(heightParam, 10, 32)
+	height, err := parseDimension(chi.URLParam(r, "height"))
 	if err != nil {
 		log.Error().Err(err).Msg("[image_previewer::PreviewImage]: failed to parse urlparam height")
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
-	imageURL, err := url.Parse(chi.URLParam(r, "*"))
+	aspectRatio, err := parseAspectRatio(r)
+	if err != nil || (aspectRatio > 0 && (width.Value == 0) == (height.Value == 0)) {
+		log.Error().Err(err).Msg("[image_previewer::PreviewImage]: aspect ratio requires exactly one dimension")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	size := resizing.Size{Width: width, Height: height, AspectRatio: aspectRatio}
+
+	if size, err = a.bucketSize(size); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::PreviewImage]: size is not allowed")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err = a.resizer.CheckSize(r.Context(), size); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::PreviewImage]: size exceeds the limits")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	imageURL, err := parseImageURL(r)
 	if err != nil {
 		log.Error().Err(err).Msg("[image_previewer::PreviewImage]: failed to parse imageurl")
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
-	val, ok := a.cache.Get(getCacheKeyForImage(imageURL.String(), widthParam, heightParam))
-	if ok {
-		cacheVal := val.(cacheValue)
-		format, resized = cacheVal.format, cacheVal.img
-	} else {
-		resized, format, err = a.resizer.GetResizedImage(r.Context(), imageURL.String(), uint(width), uint(height), r.Header)
-		if err != nil {
-			log.Error().Err(err).Msg("[image_previewer::PreviewImage]: failed to get resized image")
-			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
-			return
-		}
+	output, err := parseOutputOptions(r)
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::PreviewImage]: failed to parse output options")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
 
-		a.cache.Set(getCacheKeyForImage(imageURL.String(), widthParam, heightParam), cacheValue{
-			img:    resized,
-			format: format,
-		})
+	if err = a.resizer.CheckSource(r.Context(), imageURL); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::PreviewImage]: source is not allowed")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
 	}
 
-	var buf bytes.Buffer
+	key := getCacheKeyForImage(imageURL.String(), size, output.keepICC)
 
-	switch format {
-	case "jpeg", "jpg":
-		if err = jpeg.Encode(&buf, resized, nil); err != nil {
-			log.Error().Err(err).Msg("[image_previewer::PreviewImage]: failed to encode jpeg")
-			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+	val, _ := a.cache.Get(key)
+	if cacheVal, ok = val.(cacheValue); ok {
+		if err = a.resizer.CheckOutput(r.Context(), cacheVal.img.Bounds().Size()); err != nil {
+			log.Error().Err(err).Msg("[image_previewer::PreviewImage]: cached image exceeds the limits")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 			return
 		}
-		w.Header().Set("Content-Type", "image/jpeg")
-	case "png":
-		if err = png.Encode(&buf, resized); err != nil {
-			log.Error().Err(err).Msg("[image_previewer::PreviewImage]: failed to encode png")
-			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+	} else {
+		resized, err := a.resizer.GetResizedImage(outputContext(r, output), imageURL.String(), size, r.Header)
+		if err != nil {
+			log.Error().Err(err).Msg("[image_previewer::PreviewImage]: failed to get resized image")
+			status := upstreamErrorStatus(err)
+			http.Error(w, http.StatusText(status), status)
 			return
 		}
-		w.Header().Set("Content-Type", "image/png")
-	default:
-		log.Error().Str("format", format).Msg("[image_previewer::PreviewImage]: unsupported image format")
+
+		cacheVal = newCacheValue(resized)
+		a.cache.Set(key, cacheVal)
+	}
+
+	data, format, err := cacheVal.encode(output)
+	if err != nil {
+		log.Error().Err(err).Str("format", format).Msg("[image_previewer::PreviewImage]: failed to encode image")
 		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
 		return
 	}
 
-	if _, err = w.Write(buf.Bytes()); err != nil {
+	w.Header().Set("Content-Type", encoding.ContentType(format))
+
+	if _, err = w.Write(data); err != nil {
 		log.Error().Err(err).Msg("[image_previewer::PreviewImage]: failed to write response body")
 		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
 	}
 }
+
+// bucketSize rounds pixel dimensions of the size to the configured buckets. Percent dimensions are kept as is.
+func (a *App) bucketSize(size resizing.Size) (resizing.Size, error) {
+	var err error
+
+	if !size.Width.Percent {
+		if size.Width.Value, err = a.bucketer.Width(size.Width.Value); err != nil {
+			return resizing.Size{}, err
+		}
+	}
+
+	if !size.Height.Percent {
+		if size.Height.Value, err = a.bucketer.Height(size.Height.Value); err != nil {
+			return resizing.Size{}, err
+		}
+	}
+
+	return size, nil
+}
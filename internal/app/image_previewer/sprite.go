@@ -0,0 +1,198 @@
+package imagepreviewer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/devgomax/image-previewer/internal/pkg/encoding"
+	"github.com/devgomax/image-previewer/internal/pkg/packing"
+	"github.com/devgomax/image-previewer/internal/pkg/resizing"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	maxSpriteImages  = 256
+	maxSpritePadding = 64
+)
+
+// spriteNameRe restricts sprite names to characters valid in CSS class names.
+var spriteNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// spriteImage describes a single source of the sprite request.
+type spriteImage struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Width  uint   `json:"width"`
+	Height uint   `json:"height"`
+}
+
+// spriteRequest is the request body of the sprite request.
+type spriteRequest struct {
+	Images      []spriteImage `json:"images"`
+	Padding     int           `json:"padding"`
+	Format      string        `json:"format"`
+	ClassPrefix string        `json:"class_prefix"`
+}
+
+// spriteFrame is the position of a single image in the sprite sheet.
+type spriteFrame struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// spriteSheet is the response body of the sprite request.
+type spriteSheet struct {
+	Image  string                 `json:"image"`
+	Width  int                    `json:"width"`
+	Height int                    `json:"height"`
+	Format string                 `json:"format"`
+	Frames map[string]spriteFrame `json:"frames"`
+	CSS    string                 `json:"css"`
+}
+
+// Sprite handles the sprite sheet request.
+// It packs the requested sizes first, so sheets that don't fit are rejected before anything is fetched,
+// then fetches the source images concurrently and resizes them into their places.
+// The response contains the sheet as a data URI along with the coordinate map and CSS rules.
+func (a *App) Sprite(w http.ResponseWriter, r *http.Request) {
+	req := spriteRequest{
+		Format:      "png",
+		ClassPrefix: "sprite",
+	}
+
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Sprite]: failed to decode request body")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateSpriteRequest(&req); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Sprite]: invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	urls := make([]string, 0, len(req.Images))
+	sizes := make([]image.Point, 0, len(req.Images))
+	for _, img := range req.Images {
+		urls = append(urls, img.URL)
+		sizes = append(sizes, image.Pt(int(img.Width), int(img.Height))) //nolint:gosec
+	}
+
+	positions, bin, err := packing.Pack(sizes, req.Padding, maxCanvasSize)
+	if err != nil || bin.Y > maxCanvasSize {
+		log.Error().Err(err).Int("height", bin.Y).Msg("[image_previewer::Sprite]: images don't fit into the sprite sheet")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err = a.resizer.CheckOutput(r.Context(), bin); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Sprite]: sprite sheet exceeds the limits")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	// Sources are resized as soon as they are fetched, so the decoded sources aren't kept.
+	images, err := a.resizer.FetchImages(r.Context(), urls, r.Header, func(i int, img *resizing.Image) *resizing.Image {
+		return resizing.Resize(img, req.Images[i].Width, req.Images[i].Height)
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Sprite]: failed to fetch images")
+		status := upstreamErrorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	sheet := image.NewNRGBA(image.Rectangle{Max: bin})
+	resp := spriteSheet{
+		Width:  bin.X,
+		Height: bin.Y,
+		Format: req.Format,
+		Frames: make(map[string]spriteFrame, len(images)),
+	}
+
+	var css strings.Builder
+	for i, img := range images {
+		rect := image.Rectangle{Min: positions[i], Max: positions[i].Add(sizes[i])}
+		draw.Draw(sheet, rect, img.Image, img.Image.Bounds().Min, draw.Src)
+
+		resp.Frames[req.Images[i].Name] = spriteFrame{X: rect.Min.X, Y: rect.Min.Y, Width: rect.Dx(), Height: rect.Dy()}
+		fmt.Fprintf(&css, ".%s-%s{background-position:-%dpx -%dpx;width:%dpx;height:%dpx}\n",
+			req.ClassPrefix, req.Images[i].Name, rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy())
+	}
+
+	data, err := encoding.Encode(sheet, req.Format, encoding.Options{})
+	if err != nil {
+		log.Error().Err(err).Msg("[image_previewer::Sprite]: failed to encode sprite sheet")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp.Image = "data:" + encoding.ContentType(req.Format) + ";base64," + base64.StdEncoding.EncodeToString(data)
+	resp.CSS = css.String()
+
+	writeJSON(w, resp)
+}
+
+// validateSpriteRequest checks the sprite request and fills in default image names.
+func validateSpriteRequest(req *spriteRequest) error {
+	if len(req.Images) == 0 || len(req.Images) > maxSpriteImages {
+		return errors.Errorf("[image_previewer::validateSpriteRequest]: invalid number of images %d", len(req.Images))
+	}
+
+	if req.Padding < 0 || req.Padding > maxSpritePadding {
+		return errors.Errorf("[image_previewer::validateSpriteRequest]: invalid padding %d", req.Padding)
+	}
+
+	if req.Format != "jpeg" && req.Format != "png" {
+		return errors.Errorf("[image_previewer::validateSpriteRequest]: unsupported format %q", req.Format)
+	}
+
+	if !spriteNameRe.MatchString(req.ClassPrefix) {
+		return errors.Errorf("[image_previewer::validateSpriteRequest]: invalid class prefix %q", req.ClassPrefix)
+	}
+
+	names := make(map[string]struct{}, len(req.Images))
+	for i := range req.Images {
+		img := &req.Images[i]
+
+		if img.Name == "" {
+			img.Name = strconv.Itoa(i)
+		}
+
+		if !spriteNameRe.MatchString(img.Name) {
+			return errors.Errorf("[image_previewer::validateSpriteRequest]: invalid image name %q", img.Name)
+		}
+
+		if _, ok := names[img.Name]; ok {
+			return errors.Errorf("[image_previewer::validateSpriteRequest]: duplicate image name %q", img.Name)
+		}
+		names[img.Name] = struct{}{}
+
+		imageURL, err := url.Parse(img.URL)
+		if err != nil || img.URL == "" {
+			return errors.Errorf("[image_previewer::validateSpriteRequest]: invalid url of image %q", img.Name)
+		}
+		img.URL = imageURL.String()
+
+		if img.Width == 0 || img.Height == 0 {
+			return errors.Errorf("[image_previewer::validateSpriteRequest]: size of image %q is required", img.Name)
+		}
+
+		if img.Width > maxCanvasSize || img.Height > maxCanvasSize {
+			return errors.Errorf("[image_previewer::validateSpriteRequest]: image %q is larger than the sprite sheet", img.Name)
+		}
+	}
+
+	return nil
+}
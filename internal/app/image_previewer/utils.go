@@ -1,19 +1,278 @@
 package imagepreviewer
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"image"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/devgomax/image-previewer/internal/pkg/breaker"
+	"github.com/devgomax/image-previewer/internal/pkg/encoding"
+	"github.com/devgomax/image-previewer/internal/pkg/hostfilter"
 	"github.com/devgomax/image-previewer/internal/pkg/lru"
+	"github.com/devgomax/image-previewer/internal/pkg/metadata"
+	"github.com/devgomax/image-previewer/internal/pkg/netguard"
+	"github.com/devgomax/image-previewer/internal/pkg/resizing"
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 )
 
-// getCacheKeyForImage generates a cache key for an image based on its URL, width, and height.
-func getCacheKeyForImage(imageURL, width, height string) lru.Key {
-	return fmt.Sprintf("%v:%v:%v", imageURL, width, height)
+// getCacheKeyForImage generates a cache key for an image based on its URL and requested size.
+// Every kind of cached values has a key prefix of its own, so keys of different kinds never collide.
+// Images with the ICC profile of the source are cached separately, as the profile is only extracted on demand.
+func getCacheKeyForImage(imageURL string, size resizing.Size, keepICC bool) lru.Key {
+	prefix := "image"
+	if keepICC {
+		prefix = "image+icc"
+	}
+
+	return fmt.Sprintf("%v:%v:%v", prefix, size, imageURL)
+}
+
+// getCacheKeyForSource generates a cache key for an image at its original dimensions.
+// It's the same key as of a preview with both dimensions set to 0, as such a preview is the source itself.
+func getCacheKeyForSource(imageURL string, keepICC bool) lru.Key {
+	return getCacheKeyForImage(imageURL, resizing.Size{}, keepICC)
+}
+
+// outputContext returns the context to fetch the sources of the output with.
+func outputContext(r *http.Request, output outputOptions) context.Context {
+	if output.keepICC {
+		return resizing.NewICCContext(r.Context())
+	}
+
+	return r.Context()
+}
+
+// getCacheKeyForPlaceholder generates a cache key for an image placeholder based on its URL, kind and options.
+func getCacheKeyForPlaceholder(imageURL, kind string, xComponents, yComponents, size int) lru.Key {
+	return fmt.Sprintf("placeholder:%v:%v:%v:%v:%v", kind, xComponents, yComponents, size, imageURL)
 }
 
-// cacheValue represents the value stored in the cache. It contains the image data and its format.
+// cacheValue represents the value stored in the cache. It contains the image data, its format and ICC profile.
+// For images at source dimensions it also contains the raw upstream body.
 type cacheValue struct {
 	img    image.Image
 	format string
+	icc    []byte
+	raw    []byte
+}
+
+// newCacheValue creates a cache value from a fetched image.
+func newCacheValue(img *resizing.Image) cacheValue {
+	return cacheValue{
+		img:    img.Image,
+		format: img.Format,
+		icc:    img.ICC,
+		raw:    img.Raw,
+	}
+}
+
+// encode encodes the cached image according to the output options and returns the data along with
+// the chosen format. If no transformation is needed, the raw upstream body is passed through with its
+// metadata stripped, which avoids generational loss and saves a re-encoding.
+func (cv cacheValue) encode(output outputOptions) ([]byte, string, error) {
+	format := output.outputFormat(cv)
+
+	if cv.raw != nil && format == cv.format && output.quality == 0 {
+		data, err := metadata.Strip(cv.raw, cv.format, output.keepICC)
+		if err == nil {
+			return data, format, nil
+		}
+
+		log.Warn().Err(err).Msg("[image_previewer::cacheValue.encode]: failed to strip metadata, re-encoding image")
+	}
+
+	data, err := encoding.Encode(cv.img, format, output.encodingOptions(cv.icc))
+
+	return data, format, err
+}
+
+// cachedResponse represents a ready to send response stored in the cache.
+type cachedResponse struct {
+	contentType string
+	body        []byte
+}
+
+// write sends the cached response to the client.
+func (cr cachedResponse) write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", cr.contentType)
+
+	if _, err := w.Write(cr.body); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::cachedResponse.write]: failed to write response body")
+	}
+}
+
+// outputOptions are the encoding options shared by the routes producing images from a source.
+// Empty format means the format of the source.
+type outputOptions struct {
+	format  string
+	keepICC bool
+	quality int
+}
+
+// isOutputFormat reports whether the format can be requested for the output.
+func isOutputFormat(format string) bool {
+	return format == "jpeg" || format == "png" || format == encoding.FormatSmart
+}
+
+// parseOutputOptions parses the format, keep_icc and quality query parameters.
+func parseOutputOptions(r *http.Request) (outputOptions, error) {
+	format := r.URL.Query().Get("format")
+	if format != "" && !isOutputFormat(format) {
+		return outputOptions{}, errors.Errorf("unsupported format %q", format)
+	}
+
+	keepICC, err := parseBoolQuery(r, "keep_icc")
+	if err != nil {
+		return outputOptions{}, errors.Wrap(err, "invalid query param keep_icc")
+	}
+
+	quality, err := parseIntQuery(r, "quality", 0)
+	if err != nil || quality < 0 || quality > 100 {
+		return outputOptions{}, errors.Errorf("invalid query param quality %q", r.URL.Query().Get("quality"))
+	}
+
+	return outputOptions{format: format, keepICC: keepICC, quality: quality}, nil
+}
+
+// outputFormat returns the format the cached image should be encoded to.
+func (o outputOptions) outputFormat(cv cacheValue) string {
+	switch o.format {
+	case "":
+		return cv.format
+	case encoding.FormatSmart:
+		return encoding.Choose(cv.img)
+	default:
+		return o.format
+	}
+}
+
+// encodingOptions returns the encoding options for an image with the given source ICC profile.
+func (o outputOptions) encodingOptions(icc []byte) encoding.Options {
+	opts := encoding.Options{Quality: o.quality}
+	if o.keepICC {
+		opts.ICC = icc
+	}
+
+	return opts
+}
+
+// parseBoolQuery parses an optional boolean query parameter. Missing parameter is treated as false.
+func parseBoolQuery(r *http.Request, name string) (bool, error) {
+	param := r.URL.Query().Get(name)
+	if param == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(param)
+}
+
+// parseIntQuery parses an optional integer query parameter. Missing parameter is replaced with the default value.
+func parseIntQuery(r *http.Request, name string, def int) (int, error) {
+	param := r.URL.Query().Get(name)
+	if param == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(param)
+}
+
+// parseDimension parses a dimension URL parameter: either pixels ("300") or percent of the source ("50p").
+func parseDimension(param string) (resizing.Dimension, error) {
+	value, percent := strings.CutSuffix(param, "p")
+
+	v, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return resizing.Dimension{}, err
+	}
+
+	return resizing.Dimension{Value: uint(v), Percent: percent}, nil
+}
+
+// parseAspectRatio parses an optional aspect ratio query parameter in "16:9" notation.
+// Missing parameter is treated as 0.
+func parseAspectRatio(r *http.Request) (float64, error) {
+	param := r.URL.Query().Get("ar")
+	if param == "" {
+		return 0, nil
+	}
+
+	w, h, ok := strings.Cut(param, ":")
+	if !ok {
+		return 0, errors.Errorf("invalid aspect ratio %q", param)
+	}
+
+	width, err := strconv.ParseUint(w, 10, 32)
+	if err != nil || width == 0 {
+		return 0, errors.Errorf("invalid aspect ratio %q", param)
+	}
+
+	height, err := strconv.ParseUint(h, 10, 32)
+	if err != nil || height == 0 {
+		return 0, errors.Errorf("invalid aspect ratio %q", param)
+	}
+
+	return float64(width) / float64(height), nil
+}
+
+// parseImageURL parses the source image URL passed as the trailing wildcard of the route.
+func parseImageURL(r *http.Request) (*url.URL, error) {
+	return url.Parse(chi.URLParam(r, "*"))
+}
+
+// parseURLQuery parses a required URL query parameter.
+func parseURLQuery(r *http.Request, name string) (*url.URL, error) {
+	param := r.URL.Query().Get(name)
+	if param == "" {
+		return nil, errors.Errorf("query param %v is required", name)
+	}
+
+	return url.Parse(param)
+}
+
+// upstreamErrorStatus returns the HTTP status to respond with when fetching or decoding a source image fails.
+func upstreamErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, resizing.ErrSizeTooLarge):
+		return http.StatusBadRequest
+	case errors.Is(err, netguard.ErrBlocked), errors.Is(err, hostfilter.ErrNotAllowed),
+		errors.Is(err, resizing.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, resizing.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, breaker.ErrOpen):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, resizing.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, resizing.ErrUnsupportedFormat):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, resizing.ErrImageTooLarge), errors.Is(err, resizing.ErrBodyTooLarge),
+		errors.Is(err, resizing.ErrDecode):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// decodeJSONBody decodes a size-limited JSON request body into v. Unknown fields are rejected.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	dec.DisallowUnknownFields()
+
+	return dec.Decode(v)
+}
+
+// writeJSON writes the value as a JSON response body.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("[image_previewer::writeJSON]: failed to write response body")
+	}
 }
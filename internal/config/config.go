@@ -3,6 +3,7 @@ package config
 import (
 	"net"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
@@ -44,11 +45,98 @@ type LRUCacheConfig struct {
 	Size int `mapstructure:"size"`
 }
 
+// UpstreamConfig модель конфига для загрузки исходных изображений.
+// MaxMegapixels ограничивает количество пикселей исходного изображения, MaxBodySize - размер ответа в байтах,
+// 0 отключает ограничение.
+// Запросы к непубличным адресам (loopback, link-local, приватным и т.п.) запрещены, если не включен AllowPrivateNetworks.
+// BlockedNetworks дополнительно запрещает сети, AllowedNetworks разрешает сети несмотря на запреты.
+// AllowedHosts и DeniedHosts - шаблоны хостов вида "example.com", "*.example.com" или "example.com:8080",
+// пустой AllowedHosts разрешает все хосты, кроме запрещенных.
+// Retries - количество повторов запроса при ошибках соединения и ответах 502, 503, 504 с экспоненциальной
+// задержкой от RetryBaseDelay до RetryMaxDelay, AttemptTimeout ограничивает время одной попытки.
+// После BreakerThreshold ошибок подряд запросы к хосту отклоняются в течение BreakerTimeout, 0 отключает это.
+type UpstreamConfig struct {
+	Concurrency          int           `mapstructure:"concurrency"`
+	MaxMegapixels        float64       `mapstructure:"max_megapixels"`
+	MaxBodySize          int64         `mapstructure:"max_body_size"`
+	AllowPrivateNetworks bool          `mapstructure:"allow_private_networks"`
+	BlockedNetworks      []string      `mapstructure:"blocked_networks"`
+	AllowedNetworks      []string      `mapstructure:"allowed_networks"`
+	AllowedHosts         []string      `mapstructure:"allowed_hosts"`
+	DeniedHosts          []string      `mapstructure:"denied_hosts"`
+	Headers              HeadersConfig `mapstructure:"headers"`
+	Retries              int           `mapstructure:"retries"`
+	RetryBaseDelay       time.Duration `mapstructure:"retry_base_delay"`
+	RetryMaxDelay        time.Duration `mapstructure:"retry_max_delay"`
+	AttemptTimeout       time.Duration `mapstructure:"attempt_timeout"`
+	BreakerThreshold     int           `mapstructure:"breaker_threshold"`
+	BreakerTimeout       time.Duration `mapstructure:"breaker_timeout"`
+}
+
+// HeaderRulesConfig модель конфига для правил передачи заголовков клиента в запросы к источникам.
+// Allow - список передаваемых заголовков, пустой список разрешает все заголовки, кроме учетных данных.
+// Deny - список запрещенных заголовков, Set - заголовки, добавляемые к каждому запросу.
+type HeaderRulesConfig struct {
+	Allow []string          `mapstructure:"allow"`
+	Deny  []string          `mapstructure:"deny"`
+	Set   map[string]string `mapstructure:"set"`
+}
+
+// OriginHeadersConfig модель конфига для переопределения правил передачи заголовков для хостов по шаблону.
+type OriginHeadersConfig struct {
+	Host              string `mapstructure:"host"`
+	HeaderRulesConfig `mapstructure:",squash"`
+}
+
+// HeadersConfig модель конфига для передачи заголовков клиента в запросы к источникам.
+type HeadersConfig struct {
+	HeaderRulesConfig `mapstructure:",squash"`
+	Origins           []OriginHeadersConfig `mapstructure:"origins"`
+}
+
+// MaxPixels возвращает ограничение на количество пикселей исходного изображения.
+func (uc *UpstreamConfig) MaxPixels() int64 {
+	return int64(uc.MaxMegapixels * 1_000_000)
+}
+
+// OutputConfig модель конфига для ограничения размеров превью, 0 отключает ограничение.
+type OutputConfig struct {
+	MaxWidth      uint    `mapstructure:"max_width"`
+	MaxHeight     uint    `mapstructure:"max_height"`
+	MaxMegapixels float64 `mapstructure:"max_megapixels"`
+}
+
+// MaxPixels возвращает ограничение на количество пикселей превью.
+func (oc *OutputConfig) MaxPixels() uint64 {
+	return uint64(oc.MaxMegapixels * 1_000_000)
+}
+
+// SizeBucketsConfig модель конфига для округления запрашиваемых размеров изображений.
+// Размеры округляются вверх до ближайшего значения из списка или до кратного шагу.
+type SizeBucketsConfig struct {
+	Widths     []uint `mapstructure:"widths"`
+	Heights    []uint `mapstructure:"heights"`
+	WidthStep  uint   `mapstructure:"width_step"`
+	HeightStep uint   `mapstructure:"height_step"`
+	Strict     bool   `mapstructure:"strict"`
+}
+
+// SignatureConfig модель конфига для проверки подписи URL и JWT токенов (HS256). Первый ключ актуальный,
+// остальные принимаются для ротации ключей. Пустой список отключает проверку подписи.
+// Ключи короче 32 байт и пустые ключи не принимаются.
+type SignatureConfig struct {
+	Keys []string `mapstructure:"keys"`
+}
+
 // Config модель основного конфига приложения.
 type Config struct {
-	Logger         LoggerConfig   `mapstructure:"logger"`
-	HTTPConfig     ServerConfig   `mapstructure:"http"`
-	LRUCacheConfig LRUCacheConfig `mapstructure:"lru_cache"`
+	Logger         LoggerConfig      `mapstructure:"logger"`
+	HTTPConfig     ServerConfig      `mapstructure:"http"`
+	LRUCacheConfig LRUCacheConfig    `mapstructure:"lru_cache"`
+	UpstreamConfig UpstreamConfig    `mapstructure:"upstream"`
+	OutputConfig   OutputConfig      `mapstructure:"output"`
+	SizeBuckets    SizeBucketsConfig `mapstructure:"size_buckets"`
+	Signature      SignatureConfig   `mapstructure:"signature"`
 }
 
 // NewConfig конструктор для основного конфига приложения.
@@ -0,0 +1,155 @@
+package blurhash
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/nfnt/resize"
+	"github.com/pkg/errors"
+)
+
+const (
+	characters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+	// sampleSize is the maximum side of the downscaled copy the hash is computed on.
+	// BlurHash only keeps a few low frequency components, so full resolution adds nothing but work.
+	sampleSize = 32
+)
+
+// ErrInvalidComponents is returned when the number of components is out of the 1-9 range.
+var ErrInvalidComponents = errors.New("blurhash components must be in range 1-9")
+
+// Encode computes the BlurHash of an image with the given number of horizontal and vertical components.
+func Encode(xComponents, yComponents int, img image.Image) (string, error) {
+	if xComponents < 1 || xComponents > 9 || yComponents < 1 || yComponents > 9 {
+		return "", errors.Wrapf(ErrInvalidComponents, "[blurhash::Encode]: got %dx%d", xComponents, yComponents)
+	}
+
+	sample := resize.Thumbnail(sampleSize, sampleSize, img, resize.Bilinear)
+	pixels := linearPixels(sample)
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			factors = append(factors, basisFactor(pixels, i, j))
+		}
+	}
+
+	var hash strings.Builder
+
+	hash.WriteString(encode83((xComponents-1)+(yComponents-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+
+	maximumValue := 1.0
+	if len(ac) > 0 {
+		actualMaximum := 0.0
+		for _, f := range ac {
+			actualMaximum = math.Max(actualMaximum, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+
+		quantisedMaximum := int(math.Max(0, math.Min(82, math.Floor(actualMaximum*166-0.5))))
+		maximumValue = float64(quantisedMaximum+1) / 166
+		hash.WriteString(encode83(quantisedMaximum, 1))
+	} else {
+		hash.WriteString(encode83(0, 1))
+	}
+
+	hash.WriteString(encode83(encodeDC(dc), 4))
+
+	for _, f := range ac {
+		hash.WriteString(encode83(encodeAC(f, maximumValue), 2))
+	}
+
+	return hash.String(), nil
+}
+
+// linearPixels converts an image to rows of pixels in linear RGB.
+func linearPixels(img image.Image) [][][3]float64 {
+	b := img.Bounds()
+
+	rows := make([][][3]float64, b.Dy())
+	for y := range rows {
+		rows[y] = make([][3]float64, b.Dx())
+		for x := range rows[y] {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			rows[y][x] = [3]float64{sRGBToLinear(c.R), sRGBToLinear(c.G), sRGBToLinear(c.B)}
+		}
+	}
+
+	return rows
+}
+
+func basisFactor(pixels [][][3]float64, i, j int) [3]float64 {
+	height := len(pixels)
+	width := len(pixels[0])
+
+	normalisation := 2.0
+	if i == 0 && j == 0 {
+		normalisation = 1
+	}
+
+	var r, g, b float64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			basis := normalisation *
+				math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+				math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+
+			r += basis * pixels[y][x][0]
+			g += basis * pixels[y][x][1]
+			b += basis * pixels[y][x][2]
+		}
+	}
+
+	scale := 1 / float64(width*height)
+
+	return [3]float64{r * scale, g * scale, b * scale}
+}
+
+func encodeDC(c [3]float64) int {
+	return linearToSRGB(c[0])<<16 + linearToSRGB(c[1])<<8 + linearToSRGB(c[2])
+}
+
+func encodeAC(c [3]float64, maximumValue float64) int {
+	quant := func(v float64) int {
+		return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximumValue, 0.5)*9+9.5))))
+	}
+
+	return quant(c[0])*19*19 + quant(c[1])*19 + quant(c[2])
+}
+
+func encode83(value, length int) string {
+	var sb strings.Builder
+
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		sb.WriteByte(characters[digit])
+	}
+
+	return sb.String()
+}
+
+func sRGBToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
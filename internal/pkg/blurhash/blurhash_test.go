@@ -0,0 +1,54 @@
+package blurhash
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	t.Run("solid color", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+		hash, err := Encode(4, 3, img)
+		require.NoError(t, err)
+		require.Len(t, hash, 28)
+		require.Equal(t, "L", hash[:1])                    // size flag for 4x3 components
+		require.Equal(t, encode83(0xFFFFFF, 4), hash[2:6]) // white DC component
+	})
+
+	t.Run("length depends on components", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+		for x := 0; x < 100; x++ {
+			for y := 0; y < 50; y++ {
+				img.Set(x, y, color.RGBA{R: uint8(x * 2), G: uint8(y * 5), B: 100, A: 255})
+			}
+		}
+
+		for _, c := range [][2]int{{1, 1}, {4, 3}, {9, 9}} {
+			hash, err := Encode(c[0], c[1], img)
+			require.NoError(t, err)
+			require.Len(t, hash, 4+2*c[0]*c[1])
+		}
+	})
+
+	t.Run("invalid components", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+
+		_, err := Encode(0, 3, img)
+		require.ErrorIs(t, err, ErrInvalidComponents)
+
+		_, err = Encode(4, 10, img)
+		require.ErrorIs(t, err, ErrInvalidComponents)
+	})
+}
+
+func TestEncode83(t *testing.T) {
+	require.Equal(t, "0", encode83(0, 1))
+	require.Equal(t, "~", encode83(82, 1))
+	require.Equal(t, "10", encode83(83, 2))
+}
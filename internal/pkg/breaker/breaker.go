@@ -0,0 +1,118 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrOpen is returned when requests are rejected because of the previous failures.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// maxStates is the maximum number of keys failures are tracked for. Failures of other keys
+// aren't tracked until the states of idle keys are evicted.
+const maxStates = 10000
+
+// state is the state of the circuit of a single key.
+type state struct {
+	failures int
+	failedAt time.Time
+	openedAt time.Time
+	// probeAt is the time the last trial request was allowed at while the circuit is open.
+	probeAt time.Time
+}
+
+// Breaker is a circuit breaker tracking failures per key, like the host of an upstream. After the threshold
+// of consecutive failures the circuit opens and requests fail fast. Once the timeout passes, a single trial
+// request is let through: its success closes the circuit and its failure keeps the circuit open for another timeout.
+// Keys that neither failed nor were tried for the timeout are forgotten and start over with no failures,
+// so the states of the keys that aren't requested anymore don't pile up.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	timeout   time.Duration
+	states    map[string]*state
+	evictedAt time.Time
+	now       func() time.Time
+}
+
+// NewBreaker creates a circuit breaker opening after threshold consecutive failures for the timeout.
+func NewBreaker(threshold int, timeout time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		timeout:   timeout,
+		states:    make(map[string]*state),
+		now:       time.Now,
+	}
+}
+
+// Allow returns ErrOpen if requests for the key must fail fast. Every allowed request
+// must be followed by Success or Failure, unless it's abandoned.
+func (b *Breaker) Allow(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.states[key]
+	if !ok || s.openedAt.IsZero() {
+		return nil
+	}
+
+	now := b.now()
+	// An abandoned trial request doesn't block the next one for longer than the timeout.
+	if now.Sub(s.openedAt) < b.timeout || now.Sub(s.probeAt) < b.timeout {
+		return errors.Wrapf(ErrOpen, "[breaker::Allow]: %v", key)
+	}
+
+	s.probeAt = now
+
+	return nil
+}
+
+// Success records a successful request, closing the circuit of the key.
+func (b *Breaker) Success(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.states, key)
+}
+
+// Failure records a failed request, opening the circuit of the key after the threshold of consecutive failures.
+func (b *Breaker) Failure(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+
+	s, ok := b.states[key]
+	if !ok {
+		if b.evict(now); len(b.states) >= maxStates {
+			return
+		}
+
+		s = &state{}
+		b.states[key] = s
+	}
+
+	s.failures++
+	s.failedAt = now
+	if s.failures >= b.threshold {
+		s.openedAt = now
+	}
+}
+
+// evict forgets the keys that neither failed nor were tried for the timeout. It scans the states
+// at most once per timeout, unless there are too many of them.
+func (b *Breaker) evict(now time.Time) {
+	if now.Sub(b.evictedAt) < b.timeout && len(b.states) < maxStates {
+		return
+	}
+
+	b.evictedAt = now
+
+	for key, s := range b.states {
+		if now.Sub(s.failedAt) >= b.timeout && now.Sub(s.probeAt) >= b.timeout {
+			delete(b.states, key)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package breaker
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	b := NewBreaker(3, time.Minute)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, b.Allow("a"))
+		b.Failure("a")
+	}
+
+	// A success resets the consecutive failures.
+	b.Success("a")
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.Allow("a"))
+		b.Failure("a")
+	}
+
+	require.ErrorIs(t, b.Allow("a"), ErrOpen)
+	require.NoError(t, b.Allow("b"), "keys are independent")
+
+	now = now.Add(time.Minute)
+	require.NoError(t, b.Allow("a"), "trial request after the timeout")
+	require.ErrorIs(t, b.Allow("a"), ErrOpen, "only a single trial request")
+
+	b.Failure("a")
+	now = now.Add(time.Minute - time.Second)
+	require.ErrorIs(t, b.Allow("a"), ErrOpen, "failed trial keeps the circuit open")
+
+	now = now.Add(time.Second)
+	require.NoError(t, b.Allow("a"))
+	b.Success("a")
+
+	require.NoError(t, b.Allow("a"))
+	require.NoError(t, b.Allow("a"))
+}
+
+func TestBreakerAbandonedTrial(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	b := NewBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.Failure("a")
+	now = now.Add(time.Minute)
+	require.NoError(t, b.Allow("a"))
+
+	// The trial is never recorded, another one is allowed after the timeout.
+	now = now.Add(time.Minute)
+	require.NoError(t, b.Allow("a"))
+}
+
+func TestBreakerEviction(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.Failure("a")
+	b.Failure("b")
+	b.Failure("b")
+	require.Len(t, b.states, 2)
+
+	now = now.Add(time.Minute - time.Second)
+	b.Failure("c")
+	require.Len(t, b.states, 3, "states are kept for the timeout")
+
+	now = now.Add(time.Second)
+	b.Failure("d")
+	require.Len(t, b.states, 2, "idle states are evicted after the timeout")
+	require.Contains(t, b.states, "c")
+	require.NoError(t, b.Allow("b"))
+
+	t.Run("number of states is bounded", func(t *testing.T) {
+		b := NewBreaker(1, time.Minute)
+		b.now = func() time.Time { return now }
+
+		for i := 0; i < maxStates+10; i++ {
+			b.Failure(strconv.Itoa(i))
+		}
+
+		require.Len(t, b.states, maxStates)
+		require.NoError(t, b.Allow(strconv.Itoa(maxStates)))
+	})
+}
@@ -0,0 +1,72 @@
+package bucketing
+
+import (
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotAllowed is returned in strict mode when a dimension isn't one of the buckets.
+var ErrNotAllowed = errors.New("dimension is not allowed")
+
+// Axis rounds dimensions of one axis up to the nearest bucket. Buckets are either listed explicitly
+// or are multiples of a step, the list takes precedence. Axis without buckets accepts any dimension.
+type Axis struct {
+	buckets []uint
+	step    uint
+}
+
+// NewAxis creates an axis with the listed buckets or with buckets every step pixels.
+func NewAxis(buckets []uint, step uint) Axis {
+	sorted := slices.Clone(buckets)
+	slices.Sort(sorted)
+
+	return Axis{buckets: slices.Compact(sorted), step: step}
+}
+
+// Round returns the nearest bucket not less than the dimension. Dimensions above the largest listed
+// bucket are clamped to it. Zero means "derive from the aspect ratio" and is returned as is.
+func (a Axis) Round(v uint) uint {
+	switch {
+	case v == 0:
+		return 0
+	case len(a.buckets) > 0:
+		idx, _ := slices.BinarySearch(a.buckets, v)
+		return a.buckets[min(idx, len(a.buckets)-1)]
+	case a.step > 0:
+		return (v + a.step - 1) / a.step * a.step
+	default:
+		return v
+	}
+}
+
+// Bucketer rounds requested image sizes to buckets to improve the cache hit rate.
+type Bucketer struct {
+	width  Axis
+	height Axis
+	strict bool
+}
+
+// NewBucketer creates a bucketer. In strict mode dimensions that aren't buckets are rejected instead of rounded.
+func NewBucketer(width, height Axis, strict bool) *Bucketer {
+	return &Bucketer{width: width, height: height, strict: strict}
+}
+
+// Width rounds a width to the bucket.
+func (b *Bucketer) Width(v uint) (uint, error) {
+	return b.round(b.width, v)
+}
+
+// Height rounds a height to the bucket.
+func (b *Bucketer) Height(v uint) (uint, error) {
+	return b.round(b.height, v)
+}
+
+func (b *Bucketer) round(axis Axis, v uint) (uint, error) {
+	rounded := axis.Round(v)
+	if b.strict && rounded != v {
+		return 0, errors.Wrapf(ErrNotAllowed, "[bucketing::round]: %d", v)
+	}
+
+	return rounded, nil
+}
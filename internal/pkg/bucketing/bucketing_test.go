@@ -0,0 +1,65 @@
+package bucketing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAxis(t *testing.T) {
+	t.Run("listed buckets", func(t *testing.T) {
+		axis := NewAxis([]uint{1200, 300, 600, 600}, 100)
+
+		require.Equal(t, uint(0), axis.Round(0))
+		require.Equal(t, uint(300), axis.Round(1))
+		require.Equal(t, uint(300), axis.Round(300))
+		require.Equal(t, uint(600), axis.Round(301))
+		require.Equal(t, uint(1200), axis.Round(601))
+		require.Equal(t, uint(1200), axis.Round(5000))
+	})
+
+	t.Run("step", func(t *testing.T) {
+		axis := NewAxis(nil, 100)
+
+		require.Equal(t, uint(0), axis.Round(0))
+		require.Equal(t, uint(100), axis.Round(1))
+		require.Equal(t, uint(300), axis.Round(300))
+		require.Equal(t, uint(400), axis.Round(301))
+	})
+
+	t.Run("no buckets", func(t *testing.T) {
+		require.Equal(t, uint(317), NewAxis(nil, 0).Round(317))
+	})
+}
+
+func TestBucketer(t *testing.T) {
+	t.Run("rounding", func(t *testing.T) {
+		b := NewBucketer(NewAxis([]uint{320, 640}, 0), NewAxis(nil, 50), false)
+
+		w, err := b.Width(500)
+		require.NoError(t, err)
+		require.Equal(t, uint(640), w)
+
+		h, err := b.Height(101)
+		require.NoError(t, err)
+		require.Equal(t, uint(150), h)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		b := NewBucketer(NewAxis([]uint{320, 640}, 0), NewAxis(nil, 50), true)
+
+		w, err := b.Width(320)
+		require.NoError(t, err)
+		require.Equal(t, uint(320), w)
+
+		_, err = b.Width(500)
+		require.ErrorIs(t, err, ErrNotAllowed)
+
+		h, err := b.Height(0)
+		require.NoError(t, err)
+		require.Equal(t, uint(0), h)
+
+		_, err = b.Height(101)
+		require.ErrorIs(t, err, ErrNotAllowed)
+	})
+}
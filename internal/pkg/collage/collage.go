@@ -0,0 +1,161 @@
+package collage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/nfnt/resize"
+	"github.com/pkg/errors"
+)
+
+// Fit modes of images inside grid cells.
+const (
+	// FitContain scales an image to fit into the cell, leaving the background visible around it.
+	FitContain = "contain"
+	// FitCover scales an image to cover the whole cell, cropping what doesn't fit.
+	FitCover = "cover"
+)
+
+// ErrInvalidLayout is returned when the layout can't be composed.
+var ErrInvalidLayout = errors.New("invalid collage layout")
+
+// maxDimension is the maximum number of columns, cell size and gap of a layout,
+// so the size of the canvas can't overflow.
+const maxDimension = 1 << 16
+
+// Layout describes the grid of a collage.
+type Layout struct {
+	Columns    int
+	CellWidth  int
+	CellHeight int
+	Gap        int
+	Background color.Color
+	Fit        string
+}
+
+// Size returns the canvas size of a collage with n images. The layout must be valid.
+func (l Layout) Size(n int) image.Point {
+	rows := (n + l.Columns - 1) / l.Columns
+	columns := min(n, l.Columns)
+
+	return image.Pt(
+		columns*l.CellWidth+(columns+1)*l.Gap,
+		rows*l.CellHeight+(rows+1)*l.Gap,
+	)
+}
+
+// Validate checks that the layout describes a non-empty grid.
+func (l Layout) Validate() error {
+	if l.Columns < 1 || l.CellWidth < 1 || l.CellHeight < 1 || l.Gap < 0 {
+		return errors.Wrap(ErrInvalidLayout, "columns and cell size must be positive, gap must not be negative")
+	}
+
+	if max(l.Columns, l.CellWidth, l.CellHeight, l.Gap) > maxDimension {
+		return errors.Wrapf(ErrInvalidLayout, "columns, cell size and gap must not exceed %d", maxDimension)
+	}
+
+	if l.Fit != FitContain && l.Fit != FitCover {
+		return errors.Wrapf(ErrInvalidLayout, "unknown fit %q", l.Fit)
+	}
+
+	return nil
+}
+
+// Compose draws images into a grid row by row.
+func Compose(images []image.Image, l Layout) (*image.NRGBA, error) {
+	if err := l.Validate(); err != nil {
+		return nil, errors.Wrap(err, "[collage::Compose]")
+	}
+
+	if len(images) == 0 {
+		return nil, errors.Wrap(ErrInvalidLayout, "[collage::Compose]: no images")
+	}
+
+	size := l.Size(len(images))
+	canvas := image.NewNRGBA(image.Rectangle{Max: size})
+
+	if l.Background != nil {
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(l.Background), image.Point{}, draw.Src)
+	}
+
+	for i, img := range images {
+		column, row := i%l.Columns, i/l.Columns
+		cell := image.Rect(0, 0, l.CellWidth, l.CellHeight).Add(image.Pt(
+			l.Gap+column*(l.CellWidth+l.Gap),
+			l.Gap+row*(l.CellHeight+l.Gap),
+		))
+
+		DrawFitted(canvas, cell, img, l.Fit)
+	}
+
+	return canvas, nil
+}
+
+// Fit scales an image according to the fit mode to fit into the size. In cover mode the image is cropped
+// to the ratio of the size before scaling, so the scaled image is never larger than the size, however thin
+// the source is. Images that already fit are returned as is, so fitting an image again is cheap.
+func Fit(img image.Image, size image.Point, fit string) image.Image {
+	if img.Bounds().Empty() || size.X < 1 || size.Y < 1 {
+		return img
+	}
+
+	if fit == FitCover {
+		img = cropToRatio(img, size.X, size.Y)
+	}
+
+	b := img.Bounds()
+	scale := min(float64(size.X)/float64(b.Dx()), float64(size.Y)/float64(b.Dy()))
+
+	width := min(max(int(float64(b.Dx())*scale+0.5), 1), size.X)
+	height := min(max(int(float64(b.Dy())*scale+0.5), 1), size.Y)
+	if width == b.Dx() && height == b.Dy() {
+		return img
+	}
+
+	return resize.Resize(uint(width), uint(height), img, resize.Lanczos3) //nolint:gosec
+}
+
+// DrawFitted scales an image according to the fit mode, see Fit, and draws it centered in the rectangle.
+func DrawFitted(dst draw.Image, rect image.Rectangle, img image.Image, fit string) {
+	if img.Bounds().Empty() || rect.Empty() {
+		return
+	}
+
+	scaled := Fit(img, rect.Size(), fit)
+	b := scaled.Bounds()
+	offset := image.Pt((rect.Dx()-b.Dx())/2, (rect.Dy()-b.Dy())/2)
+
+	draw.Draw(dst, image.Rectangle{Min: rect.Min.Add(offset), Max: rect.Min.Add(offset).Add(b.Size())}, scaled, b.Min, draw.Over)
+}
+
+// cropToRatio returns the centered part of the image having the width to height ratio.
+func cropToRatio(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	crop := b
+
+	if b.Dx()*height > width*b.Dy() {
+		w := max(b.Dy()*width/height, 1)
+		crop.Min.X += (b.Dx() - w) / 2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := max(b.Dx()*height/width, 1)
+		crop.Min.Y += (b.Dy() - h) / 2
+		crop.Max.Y = crop.Min.Y + h
+	}
+
+	if crop == b {
+		return img
+	}
+
+	if sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(crop)
+	}
+
+	cropped := image.NewNRGBA(image.Rectangle{Max: crop.Size()})
+	draw.Draw(cropped, cropped.Bounds(), img, crop.Min, draw.Src)
+
+	return cropped
+}
@@ -0,0 +1,92 @@
+package collage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func solid(width, height int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestCompose(t *testing.T) {
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	blue := color.NRGBA{B: 0xff, A: 0xff}
+	white := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+	t.Run("grid", func(t *testing.T) {
+		layout := Layout{Columns: 2, CellWidth: 10, CellHeight: 10, Gap: 2, Background: white, Fit: FitCover}
+
+		canvas, err := Compose([]image.Image{solid(20, 40, red), solid(5, 5, blue), solid(10, 10, red)}, layout)
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, 2*10+3*2, 2*10+3*2), canvas.Bounds())
+
+		require.Equal(t, white, canvas.NRGBAAt(0, 0))   // gap
+		require.Equal(t, red, canvas.NRGBAAt(2, 2))     // first cell
+		require.Equal(t, blue, canvas.NRGBAAt(14, 2))   // second cell
+		require.Equal(t, red, canvas.NRGBAAt(2, 14))    // third cell
+		require.Equal(t, white, canvas.NRGBAAt(14, 14)) // empty cell
+	})
+
+	t.Run("contain keeps background around image", func(t *testing.T) {
+		layout := Layout{Columns: 1, CellWidth: 20, CellHeight: 10, Background: white, Fit: FitContain}
+
+		canvas, err := Compose([]image.Image{solid(10, 10, red)}, layout)
+		require.NoError(t, err)
+		require.Equal(t, white, canvas.NRGBAAt(0, 5))
+		require.Equal(t, red, canvas.NRGBAAt(10, 5))
+	})
+
+	t.Run("cover crops thin image to the cell", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 2, 10000))
+		draw.Draw(img, img.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(0, 4990, 2, 5010), image.NewUniform(blue), image.Point{}, draw.Src)
+
+		layout := Layout{Columns: 1, CellWidth: 512, CellHeight: 512, Background: white, Fit: FitCover}
+
+		canvas, err := Compose([]image.Image{img}, layout)
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, 512, 512), canvas.Bounds())
+		require.Equal(t, blue, canvas.NRGBAAt(0, 0))
+		require.Equal(t, blue, canvas.NRGBAAt(511, 511))
+	})
+
+	t.Run("fitted images are not fitted again", func(t *testing.T) {
+		fitted := Fit(solid(20, 40, red), image.Pt(10, 10), FitCover)
+		require.Equal(t, image.Pt(10, 10), fitted.Bounds().Size())
+		require.Same(t, fitted, Fit(fitted, image.Pt(10, 10), FitCover))
+
+		fitted = Fit(solid(20, 40, red), image.Pt(10, 10), FitContain)
+		require.Equal(t, image.Pt(5, 10), fitted.Bounds().Size())
+		require.Same(t, fitted, Fit(fitted, image.Pt(10, 10), FitContain))
+	})
+
+	t.Run("single row is not wider than images", func(t *testing.T) {
+		layout := Layout{Columns: 4, CellWidth: 10, CellHeight: 10, Fit: FitContain}
+		require.Equal(t, image.Pt(20, 10), layout.Size(2))
+	})
+
+	t.Run("invalid layout", func(t *testing.T) {
+		_, err := Compose([]image.Image{solid(1, 1, red)}, Layout{Columns: 0, CellWidth: 1, CellHeight: 1, Fit: FitCover})
+		require.ErrorIs(t, err, ErrInvalidLayout)
+
+		_, err = Compose([]image.Image{solid(1, 1, red)}, Layout{Columns: 1, CellWidth: 1, CellHeight: 1, Fit: "stretch"})
+		require.ErrorIs(t, err, ErrInvalidLayout)
+
+		_, err = Compose(nil, Layout{Columns: 1, CellWidth: 1, CellHeight: 1, Fit: FitCover})
+		require.ErrorIs(t, err, ErrInvalidLayout)
+
+		// The canvas width would overflow to 0.
+		overflow := Layout{Columns: 4, CellWidth: 1 << 62, CellHeight: 100, Fit: FitCover}
+		require.ErrorIs(t, overflow.Validate(), ErrInvalidLayout)
+
+		overflow = Layout{Columns: 1, CellWidth: 1, CellHeight: 1, Gap: 1 << 62, Fit: FitCover}
+		require.ErrorIs(t, overflow.Validate(), ErrInvalidLayout)
+	})
+}
@@ -0,0 +1,278 @@
+package colors
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nfnt/resize"
+	"github.com/pkg/errors"
+)
+
+// sampleSize is the maximum side of the downscaled copy used for color analysis.
+const sampleSize = 64
+
+// Hex formats a color as "#rrggbb".
+func Hex(c color.Color) string {
+	rgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
+
+// HasAlpha reports whether an image contains at least one pixel that is not fully opaque.
+func HasAlpha(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return !o.Opaque()
+	}
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ModelName returns a human-readable name of the color model.
+func ModelName(m color.Model) string {
+	if _, ok := m.(color.Palette); ok {
+		return "paletted"
+	}
+
+	switch m {
+	case color.RGBAModel:
+		return "rgba"
+	case color.RGBA64Model:
+		return "rgba64"
+	case color.NRGBAModel:
+		return "nrgba"
+	case color.NRGBA64Model:
+		return "nrgba64"
+	case color.AlphaModel:
+		return "alpha"
+	case color.Alpha16Model:
+		return "alpha16"
+	case color.GrayModel:
+		return "gray"
+	case color.Gray16Model:
+		return "gray16"
+	case color.YCbCrModel:
+		return "ycbcr"
+	case color.NYCbCrAModel:
+		return "nycbcra"
+	case color.CMYKModel:
+		return "cmyk"
+	default:
+		return "unknown"
+	}
+}
+
+// Dominant returns the most common color of an image. Colors are counted on a downscaled copy
+// with channels quantized to 4 bits, the result is the average of the most populated bucket.
+// Transparent pixels are ignored.
+func Dominant(img image.Image) color.NRGBA {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+
+	var buckets [4096]bucket
+
+	best := -1
+	forEachPixel(img, func(c color.NRGBA) {
+		idx := int(c.R>>4)<<8 | int(c.G>>4)<<4 | int(c.B>>4)
+
+		bk := &buckets[idx]
+		bk.count++
+		bk.r += int(c.R)
+		bk.g += int(c.G)
+		bk.b += int(c.B)
+
+		if best < 0 || bk.count > buckets[best].count {
+			best = idx
+		}
+	})
+
+	if best < 0 {
+		return color.NRGBA{}
+	}
+
+	bk := buckets[best]
+
+	return color.NRGBA{
+		R: uint8(bk.r / bk.count), //nolint:gosec
+		G: uint8(bk.g / bk.count), //nolint:gosec
+		B: uint8(bk.b / bk.count), //nolint:gosec
+		A: 0xff,
+	}
+}
+
+// forEachPixel calls fn for every non-transparent pixel of a downscaled copy of the image.
+func forEachPixel(img image.Image, fn func(c color.NRGBA)) {
+	sample := resize.Thumbnail(sampleSize, sampleSize, img, resize.Bilinear)
+
+	b := sample.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(sample.At(x, y)).(color.NRGBA)
+			if c.A < 0x80 {
+				continue
+			}
+			fn(c)
+		}
+	}
+}
+
+// Swatch is a color of an image palette along with the share of pixels it represents.
+type Swatch struct {
+	Color      color.NRGBA
+	Population float64
+}
+
+// Palette extracts up to n dominant colors of an image using the median cut algorithm
+// on a downscaled copy. Swatches are sorted by population in descending order.
+func Palette(img image.Image, n int) []Swatch {
+	var pixels []color.NRGBA
+	forEachPixel(img, func(c color.NRGBA) {
+		pixels = append(pixels, c)
+	})
+
+	if len(pixels) == 0 || n < 1 {
+		return nil
+	}
+
+	boxes := []colorBox{{pixels: pixels}}
+	for len(boxes) < n {
+		idx, channel := -1, 0
+		widest := 0
+
+		// Split the box with the widest channel range weighted by its population.
+		for i, b := range boxes {
+			if len(b.pixels) < 2 {
+				continue
+			}
+
+			ch, rng := b.widestChannel()
+			if score := rng * len(b.pixels); rng > 0 && score > widest {
+				idx, channel, widest = i, ch, score
+			}
+		}
+
+		if idx < 0 {
+			break
+		}
+
+		left, right := boxes[idx].split(channel)
+		boxes[idx] = left
+		boxes = append(boxes, right)
+	}
+
+	swatches := make([]Swatch, 0, len(boxes))
+	for _, b := range boxes {
+		swatches = append(swatches, Swatch{
+			Color:      b.average(),
+			Population: float64(len(b.pixels)) / float64(len(pixels)),
+		})
+	}
+
+	sort.SliceStable(swatches, func(i, j int) bool {
+		return swatches[i].Population > swatches[j].Population
+	})
+
+	return swatches
+}
+
+// colorBox is a group of pixels of the median cut algorithm.
+type colorBox struct {
+	pixels []color.NRGBA
+}
+
+func channelValue(c color.NRGBA, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	default:
+		return c.B
+	}
+}
+
+// widestChannel returns the channel with the largest value range and the range itself.
+func (b colorBox) widestChannel() (int, int) {
+	channel, widest := 0, -1
+
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, p := range b.pixels {
+			v := channelValue(p, ch)
+			lo, hi = min(lo, v), max(hi, v)
+		}
+
+		if rng := int(hi) - int(lo); rng > widest {
+			channel, widest = ch, rng
+		}
+	}
+
+	return channel, widest
+}
+
+// split sorts the pixels by the channel and cuts the box near the median. The cut is moved
+// to the closest boundary between distinct values, so pixels of the same color are never separated.
+// The box must have a non-zero range in the channel.
+func (b colorBox) split(channel int) (colorBox, colorBox) {
+	sort.Slice(b.pixels, func(i, j int) bool {
+		return channelValue(b.pixels[i], channel) < channelValue(b.pixels[j], channel)
+	})
+
+	median := len(b.pixels) / 2
+	value := channelValue(b.pixels[median], channel)
+
+	lower := sort.Search(len(b.pixels), func(i int) bool { return channelValue(b.pixels[i], channel) >= value })
+	upper := sort.Search(len(b.pixels), func(i int) bool { return channelValue(b.pixels[i], channel) > value })
+
+	cut := upper
+	if upper == len(b.pixels) || (lower > 0 && median-lower <= upper-median) {
+		cut = lower
+	}
+
+	return colorBox{pixels: b.pixels[:cut]}, colorBox{pixels: b.pixels[cut:]}
+}
+
+func (b colorBox) average() color.NRGBA {
+	var r, g, bl int
+	for _, p := range b.pixels {
+		r += int(p.R)
+		g += int(p.G)
+		bl += int(p.B)
+	}
+
+	n := len(b.pixels)
+
+	return color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: 0xff} //nolint:gosec
+}
+
+// ParseHex parses a color in "#rgb", "#rrggbb" or "#rrggbbaa" notation. The leading "#" is optional.
+func ParseHex(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 8 {
+		return color.NRGBA{}, errors.Errorf("[colors::ParseHex]: invalid color %q", s)
+	}
+
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil //nolint:gosec
+}
@@ -0,0 +1,78 @@
+package colors
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDominant(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 200, G: 10, B: 10, A: 255}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 30, 100), image.NewUniform(color.RGBA{B: 250, A: 255}), image.Point{}, draw.Src)
+
+	require.Equal(t, "#c80a0a", Hex(Dominant(img)))
+}
+
+func TestHasAlpha(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	require.False(t, HasAlpha(img))
+
+	img.Set(5, 5, color.NRGBA{A: 10})
+	require.True(t, HasAlpha(img))
+
+	require.False(t, HasAlpha(image.NewGray(image.Rect(0, 0, 10, 10))))
+}
+
+func TestModelName(t *testing.T) {
+	require.Equal(t, "ycbcr", ModelName(color.YCbCrModel))
+	require.Equal(t, "paletted", ModelName(color.Palette{color.Black}))
+	require.Equal(t, "gray", ModelName(color.GrayModel))
+}
+
+func TestPalette(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 60, 60))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 60, 15), image.NewUniform(color.RGBA{G: 255, A: 255}), image.Point{}, draw.Src)
+
+	t.Run("two colors", func(t *testing.T) {
+		palette := Palette(img, 2)
+		require.Len(t, palette, 2)
+
+		require.Equal(t, "#ff0000", Hex(palette[0].Color))
+		require.InDelta(t, 0.75, palette[0].Population, 0.001)
+		require.Equal(t, "#00ff00", Hex(palette[1].Color))
+		require.InDelta(t, 0.25, palette[1].Population, 0.001)
+	})
+
+	t.Run("fewer colors than requested", func(t *testing.T) {
+		solid := image.NewRGBA(image.Rect(0, 0, 10, 10))
+		draw.Draw(solid, solid.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		require.Len(t, Palette(solid, 5), 1)
+	})
+
+	t.Run("invalid count", func(t *testing.T) {
+		require.Nil(t, Palette(img, 0))
+	})
+}
+
+func TestParseHex(t *testing.T) {
+	for in, expected := range map[string]color.NRGBA{
+		"#fff":      {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		"c80a0a":    {R: 0xc8, G: 0x0a, B: 0x0a, A: 0xff},
+		"#00ff0080": {G: 0xff, A: 0x80},
+	} {
+		c, err := ParseHex(in)
+		require.NoError(t, err)
+		require.Equal(t, expected, c)
+	}
+
+	for _, in := range []string{"", "#ff", "#gggggg", "#1234567"} {
+		_, err := ParseHex(in)
+		require.Error(t, err, in)
+	}
+}
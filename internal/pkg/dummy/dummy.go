@@ -0,0 +1,114 @@
+package dummy
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/pkg/errors"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Background patterns supported by Render.
+const (
+	PatternSolid   = "solid"
+	PatternChecker = "checker"
+	PatternStripes = "stripes"
+)
+
+// ErrUnknownPattern is returned when a background pattern isn't supported.
+var ErrUnknownPattern = errors.New("unknown pattern")
+
+// Options describes a placeholder image.
+type Options struct {
+	Width      int
+	Height     int
+	Background color.Color
+	Foreground color.Color
+	Text       string
+	Pattern    string
+}
+
+// Render draws a placeholder image: a background filled with the pattern and the text centered on top of it.
+// The text is scaled up by an integer factor to take a reasonable part of the image.
+func Render(opts Options) (*image.NRGBA, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+
+	if err := fillPattern(img, opts.Pattern, opts.Background); err != nil {
+		return nil, errors.Wrap(err, "[dummy::Render]")
+	}
+
+	if opts.Text != "" {
+		drawText(img, opts.Text, opts.Foreground)
+	}
+
+	return img, nil
+}
+
+// fillPattern fills an image with the background pattern. Patterned backgrounds alternate between
+// the background color and its slightly darker shade.
+func fillPattern(img *image.NRGBA, pattern string, bg color.Color) error {
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	const cell = 16
+
+	var inShade func(x, y int) bool
+	switch pattern {
+	case "", PatternSolid:
+		return nil
+	case PatternChecker:
+		inShade = func(x, y int) bool { return (x/cell+y/cell)%2 == 1 }
+	case PatternStripes:
+		inShade = func(x, y int) bool { return ((x+y)/cell)%2 == 1 }
+	default:
+		return errors.Wrapf(ErrUnknownPattern, "%q", pattern)
+	}
+
+	base := color.NRGBAModel.Convert(bg).(color.NRGBA)
+	shade := color.NRGBA{R: base.R - base.R/8, G: base.G - base.G/8, B: base.B - base.B/8, A: base.A}
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if inShade(x, y) {
+				img.SetNRGBA(x, y, shade)
+			}
+		}
+	}
+
+	return nil
+}
+
+// drawText renders the text with the built-in bitmap font and draws it centered on the image.
+func drawText(img *image.NRGBA, text string, fg color.Color) {
+	face := basicfont.Face7x13
+
+	textWidth := font.MeasureString(face, text).Ceil()
+	textHeight := face.Metrics().Height.Ceil()
+	if textWidth == 0 {
+		return
+	}
+
+	mask := image.NewAlpha(image.Rect(0, 0, textWidth, textHeight))
+	d := font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: face,
+		Dot:  fixed.P(0, face.Metrics().Ascent.Ceil()),
+	}
+	d.DrawString(text)
+
+	b := img.Bounds()
+	scale := max(min(b.Dx()*4/5/textWidth, b.Dy()/2/textHeight), 1)
+
+	scaled := image.NewAlpha(image.Rect(0, 0, textWidth*scale, textHeight*scale))
+	xdraw.NearestNeighbor.Scale(scaled, scaled.Bounds(), mask, mask.Bounds(), draw.Src, nil)
+
+	offset := image.Pt((b.Dx()-scaled.Rect.Dx())/2, (b.Dy()-scaled.Rect.Dy())/2)
+	target := scaled.Bounds().Add(b.Min).Add(offset)
+
+	draw.DrawMask(img, target, image.NewUniform(fg), image.Point{}, scaled, image.Point{}, draw.Over)
+}
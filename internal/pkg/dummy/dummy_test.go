@@ -0,0 +1,53 @@
+package dummy
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	bg := color.NRGBA{R: 0xcc, G: 0xcc, B: 0xcc, A: 0xff}
+	fg := color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff}
+
+	countColor := func(img *image.NRGBA, c color.NRGBA) int {
+		n := 0
+		for y := 0; y < img.Rect.Dy(); y++ {
+			for x := 0; x < img.Rect.Dx(); x++ {
+				if img.NRGBAAt(x, y) == c {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	t.Run("solid without text", func(t *testing.T) {
+		img, err := Render(Options{Width: 30, Height: 20, Background: bg, Foreground: fg})
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, 30, 20), img.Bounds())
+		require.Equal(t, 30*20, countColor(img, bg))
+	})
+
+	t.Run("text is drawn in the center", func(t *testing.T) {
+		img, err := Render(Options{Width: 300, Height: 100, Background: bg, Foreground: fg, Text: "300x100"})
+		require.NoError(t, err)
+		require.Positive(t, countColor(img, fg))
+		require.Equal(t, bg, img.NRGBAAt(0, 0))
+		require.Equal(t, bg, img.NRGBAAt(299, 99))
+	})
+
+	t.Run("patterns", func(t *testing.T) {
+		for _, pattern := range []string{PatternChecker, PatternStripes} {
+			img, err := Render(Options{Width: 64, Height: 64, Background: bg, Pattern: pattern})
+			require.NoError(t, err)
+			require.Less(t, countColor(img, bg), 64*64)
+			require.Positive(t, countColor(img, bg))
+		}
+
+		_, err := Render(Options{Width: 64, Height: 64, Background: bg, Pattern: "dots"})
+		require.ErrorIs(t, err, ErrUnknownPattern)
+	})
+}
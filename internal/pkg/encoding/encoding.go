@@ -0,0 +1,157 @@
+package encoding
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/devgomax/image-previewer/internal/pkg/colors"
+	"github.com/devgomax/image-previewer/internal/pkg/metadata"
+	"github.com/pkg/errors"
+)
+
+// ErrUnsupportedFormat is returned when an image can't be encoded to the requested format.
+var ErrUnsupportedFormat = errors.New("unsupported output format")
+
+// Options describes how an image should be encoded.
+type Options struct {
+	// ICC is the color profile to embed into the output. Nothing is embedded if it's empty.
+	ICC []byte
+	// Quality is the JPEG quality in range 1-100. Zero means the default quality.
+	Quality int
+}
+
+// Encode encodes an image to the specified format. Source metadata (EXIF, XMP, ICC) is never carried over
+// implicitly, only the ICC profile from opts is written to the output. JPEG has no alpha channel,
+// so translucent images are flattened onto white before encoding.
+func Encode(img image.Image, format string, opts Options) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch format {
+	case "jpeg", "jpg":
+		if colors.HasAlpha(img) {
+			img = flatten(img, color.White)
+		}
+
+		var jpegOpts *jpeg.Options
+		if opts.Quality > 0 {
+			jpegOpts = &jpeg.Options{Quality: opts.Quality}
+		}
+
+		if err := jpeg.Encode(&buf, img, jpegOpts); err != nil {
+			return nil, errors.Wrap(err, "[encoding::Encode]: failed to encode jpeg")
+		}
+	case "png":
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, errors.Wrap(err, "[encoding::Encode]: failed to encode png")
+		}
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedFormat, "[encoding::Encode]: %q", format)
+	}
+
+	data, err := metadata.EmbedICC(buf.Bytes(), format, opts.ICC)
+	if err != nil {
+		return nil, errors.Wrap(err, "[encoding::Encode]: failed to embed ICC profile")
+	}
+
+	return data, nil
+}
+
+// ContentType returns the MIME type for the image format.
+func ContentType(format string) string {
+	switch format {
+	case "jpeg", "jpg":
+		return "image/jpeg"
+	case "png":
+		return "image/png"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// flatten draws an image over a solid background color.
+func flatten(img image.Image, background color.Color) *image.NRGBA {
+	out := image.NewNRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	return out
+}
+
+// FormatSmart is the pseudo format choosing the output format by the image content.
+const FormatSmart = "smart"
+
+const (
+	// smartSampleSize is the maximum number of pixels per side analyzed by Choose.
+	smartSampleSize = 256
+
+	// maxPaletteColors is the number of distinct colors up to which an image is considered flat graphics.
+	maxPaletteColors = 256
+
+	// minFlatRatio is the share of identical neighbouring pixels from which an image is considered flat graphics.
+	minFlatRatio = 0.5
+)
+
+// Choose picks the output format by the image content. Images with transparency and flat graphics
+// (few distinct colors or large areas of identical pixels, like screenshots and logos) are better served
+// as PNG, everything else is considered a photograph and is served as JPEG.
+func Choose(img image.Image) string {
+	if colors.HasAlpha(img) {
+		return "png"
+	}
+
+	b := img.Bounds()
+	if b.Empty() {
+		return "png"
+	}
+
+	stepX := max(b.Dx()/smartSampleSize, 1)
+	stepY := max(b.Dy()/smartSampleSize, 1)
+
+	var (
+		distinct   = make(map[color.NRGBA]struct{}, maxPaletteColors+1)
+		pairs      int
+		identical  int
+		prevRow    []color.NRGBA
+		currentRow []color.NRGBA
+	)
+
+	for y := b.Min.Y; y < b.Max.Y; y += stepY {
+		currentRow = currentRow[:0]
+
+		for x := b.Min.X; x < b.Max.X; x += stepX {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+
+			if len(distinct) <= maxPaletteColors {
+				distinct[c] = struct{}{}
+			}
+
+			if i := len(currentRow); i > 0 {
+				pairs++
+				if currentRow[i-1] == c {
+					identical++
+				}
+			}
+
+			if i := len(currentRow); i < len(prevRow) {
+				pairs++
+				if prevRow[i] == c {
+					identical++
+				}
+			}
+
+			currentRow = append(currentRow, c)
+		}
+
+		prevRow, currentRow = currentRow, prevRow
+	}
+
+	if len(distinct) <= maxPaletteColors || (pairs > 0 && float64(identical)/float64(pairs) >= minFlatRatio) {
+		return "png"
+	}
+
+	return "jpeg"
+}
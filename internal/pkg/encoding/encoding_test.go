@@ -0,0 +1,109 @@
+package encoding
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/devgomax/image-previewer/internal/pkg/metadata"
+	"github.com/stretchr/testify/require"
+)
+
+func noise(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8(x * y), A: 0xff})
+		}
+	}
+	return img
+}
+
+func TestEncode(t *testing.T) {
+	t.Run("formats", func(t *testing.T) {
+		for _, format := range []string{"jpeg", "png"} {
+			data, err := Encode(noise(16, 8), format, Options{})
+			require.NoError(t, err)
+
+			img, decoded, err := image.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			require.Equal(t, format, decoded)
+			require.Equal(t, image.Rect(0, 0, 16, 8), img.Bounds())
+		}
+	})
+
+	t.Run("jpeg quality", func(t *testing.T) {
+		low, err := Encode(noise(64, 64), "jpeg", Options{Quality: 10})
+		require.NoError(t, err)
+
+		high, err := Encode(noise(64, 64), "jpeg", Options{Quality: 95})
+		require.NoError(t, err)
+
+		require.Less(t, len(low), len(high))
+	})
+
+	t.Run("transparent jpeg is flattened onto white", func(t *testing.T) {
+		data, err := Encode(image.NewNRGBA(image.Rect(0, 0, 8, 8)), "jpeg", Options{})
+		require.NoError(t, err)
+
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		r, g, b, _ := img.At(4, 4).RGBA()
+		require.Greater(t, r>>8, uint32(250))
+		require.Greater(t, g>>8, uint32(250))
+		require.Greater(t, b>>8, uint32(250))
+	})
+
+	t.Run("icc profile", func(t *testing.T) {
+		icc := []byte("profile")
+
+		data, err := Encode(noise(8, 8), "png", Options{ICC: icc})
+		require.NoError(t, err)
+		require.Equal(t, icc, metadata.ExtractICC(data, "png"))
+
+		_, err = png.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := Encode(noise(8, 8), "gif", Options{})
+		require.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+}
+
+func TestChoose(t *testing.T) {
+	t.Run("photo", func(t *testing.T) {
+		require.Equal(t, "jpeg", Choose(noise(300, 200)))
+	})
+
+	t.Run("transparency", func(t *testing.T) {
+		img := noise(300, 200)
+		img.Set(10, 10, color.NRGBA{A: 0x80})
+		require.Equal(t, "png", Choose(img))
+	})
+
+	t.Run("few colors", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+		for x := 0; x < 300; x++ {
+			for y := 0; y < 200; y++ {
+				img.Set(x, y, color.NRGBA{R: uint8(x % 16 * 16), G: uint8(y % 8 * 32), A: 0xff})
+			}
+		}
+		require.Equal(t, "png", Choose(img))
+	})
+
+	t.Run("screenshot", func(t *testing.T) {
+		// Large flat areas with a noisy region: many colors, but mostly identical neighbours.
+		img := noise(300, 200)
+		for x := 0; x < 300; x++ {
+			for y := 0; y < 150; y++ {
+				img.Set(x, y, color.NRGBA{R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff})
+			}
+		}
+		require.Equal(t, "png", Choose(img))
+	})
+}
@@ -0,0 +1,165 @@
+package headerpolicy
+
+import (
+	"maps"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/devgomax/image-previewer/internal/pkg/hostfilter"
+)
+
+// strippedHeaders are never forwarded. Hop-by-hop headers are meaningful for a single connection only,
+// the rest are managed by the HTTP client or would make the upstream respond with something
+// other than the full image.
+var strippedHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	"Host",
+	"Content-Length",
+	"Accept-Encoding",
+	"Range",
+	"If-Range",
+	"If-Match",
+	"If-None-Match",
+	"If-Modified-Since",
+	"If-Unmodified-Since",
+}
+
+// credentialHeaders are not forwarded unless they are explicitly allowed.
+var credentialHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+}
+
+// Rules describes which client headers are forwarded to an upstream.
+type Rules struct {
+	// Allow lists the only headers to forward. Empty list allows every header except the credential ones.
+	Allow []string
+	// Deny lists the headers that are never forwarded.
+	Deny []string
+	// Set lists the headers added to every request. They replace the client headers of the same name.
+	Set map[string]string
+}
+
+// origin is an override of the rules for the hosts matching the pattern.
+type origin struct {
+	pattern hostfilter.Pattern
+	rules   Rules
+}
+
+// Policy builds the headers of upstream requests from the headers of client requests.
+type Policy struct {
+	defaults Rules
+	origins  []origin
+}
+
+// NewPolicy creates a policy with the default rules.
+func NewPolicy(defaults Rules) *Policy {
+	return &Policy{defaults: defaults}
+}
+
+// Override sets the rules for the hosts matching the pattern, see hostfilter.ParsePattern.
+// Allow and Deny lists of the override replace the default ones if they are set, Set headers are added
+// to the default ones. The first matching override applies.
+func (p *Policy) Override(pattern string, rules Rules) error {
+	hp, err := hostfilter.ParsePattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	p.origins = append(p.origins, origin{pattern: hp, rules: rules})
+
+	return nil
+}
+
+// Apply returns the headers to send to the upstream URL for a client request with the headers.
+func (p *Policy) Apply(u *url.URL, header http.Header) http.Header {
+	rules := p.rules(u)
+
+	stripped := make(map[string]struct{}, len(strippedHeaders))
+	for _, name := range strippedHeaders {
+		stripped[name] = struct{}{}
+	}
+	// Headers listed in Connection are hop-by-hop as well.
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			stripped[textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))] = struct{}{}
+		}
+	}
+
+	allowed := canonicalSet(rules.Allow)
+	denied := canonicalSet(rules.Deny)
+	if len(allowed) == 0 {
+		for _, name := range credentialHeaders {
+			denied[name] = struct{}{}
+		}
+	}
+
+	out := make(http.Header, len(header)+len(rules.Set))
+	for name, values := range header {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+
+		if _, ok := stripped[name]; ok {
+			continue
+		}
+		if _, ok := denied[name]; ok {
+			continue
+		}
+		if _, ok := allowed[name]; len(allowed) > 0 && !ok {
+			continue
+		}
+
+		out[name] = append(out[name], values...)
+	}
+
+	for name, value := range rules.Set {
+		out.Set(name, value)
+	}
+
+	return out
+}
+
+// rules returns the rules for the upstream URL.
+func (p *Policy) rules(u *url.URL) Rules {
+	for _, o := range p.origins {
+		if !o.pattern.Match(u) {
+			continue
+		}
+
+		rules := Rules{
+			Allow: p.defaults.Allow,
+			Deny:  p.defaults.Deny,
+			Set:   make(map[string]string, len(p.defaults.Set)+len(o.rules.Set)),
+		}
+		if len(o.rules.Allow) > 0 {
+			rules.Allow = o.rules.Allow
+		}
+		if len(o.rules.Deny) > 0 {
+			rules.Deny = o.rules.Deny
+		}
+		maps.Copy(rules.Set, p.defaults.Set)
+		maps.Copy(rules.Set, o.rules.Set)
+
+		return rules
+	}
+
+	return p.defaults
+}
+
+func canonicalSet(names []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))] = struct{}{}
+	}
+
+	return set
+}
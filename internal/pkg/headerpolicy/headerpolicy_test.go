@@ -0,0 +1,77 @@
+package headerpolicy
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func clientHeader() http.Header {
+	return http.Header{
+		"Accept":          {"image/*"},
+		"User-Agent":      {"test"},
+		"Authorization":   {"Bearer secret"},
+		"Cookie":          {"session=secret"},
+		"Connection":      {"keep-alive, X-Hop"},
+		"X-Hop":           {"1"},
+		"Keep-Alive":      {"timeout=5"},
+		"Accept-Encoding": {"gzip"},
+		"If-None-Match":   {`"etag"`},
+		"X-Request-Id":    {"42"},
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	u := &url.URL{Scheme: "http", Host: "example.com"}
+
+	out := NewPolicy(Rules{}).Apply(u, clientHeader())
+	require.Equal(t, http.Header{
+		"Accept":       {"image/*"},
+		"User-Agent":   {"test"},
+		"X-Request-Id": {"42"},
+	}, out)
+
+	require.Empty(t, NewPolicy(Rules{}).Apply(u, nil))
+}
+
+func TestApplyRules(t *testing.T) {
+	u := &url.URL{Scheme: "http", Host: "example.com"}
+
+	t.Run("allow", func(t *testing.T) {
+		out := NewPolicy(Rules{Allow: []string{"accept", "cookie", "connection"}}).Apply(u, clientHeader())
+		require.Equal(t, http.Header{"Accept": {"image/*"}, "Cookie": {"session=secret"}}, out)
+	})
+
+	t.Run("deny", func(t *testing.T) {
+		out := NewPolicy(Rules{Deny: []string{"User-Agent", "x-request-id"}}).Apply(u, clientHeader())
+		require.Equal(t, http.Header{"Accept": {"image/*"}}, out)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		out := NewPolicy(Rules{Allow: []string{"Accept", "User-Agent"}, Set: map[string]string{"user-agent": "previewer"}}).
+			Apply(u, clientHeader())
+		require.Equal(t, http.Header{"Accept": {"image/*"}, "User-Agent": {"previewer"}}, out)
+	})
+}
+
+func TestOverride(t *testing.T) {
+	p := NewPolicy(Rules{Allow: []string{"Accept"}, Set: map[string]string{"X-Service": "previewer"}})
+	require.NoError(t, p.Override("*.internal.test", Rules{
+		Allow: []string{"Accept", "Authorization"},
+		Set:   map[string]string{"X-Api-Key": "key"},
+	}))
+	require.Error(t, p.Override("a.*.test", Rules{}))
+
+	out := p.Apply(&url.URL{Scheme: "https", Host: "img.internal.test"}, clientHeader())
+	require.Equal(t, http.Header{
+		"Accept":        {"image/*"},
+		"Authorization": {"Bearer secret"},
+		"X-Service":     {"previewer"},
+		"X-Api-Key":     {"key"},
+	}, out)
+
+	out = p.Apply(&url.URL{Scheme: "https", Host: "example.com"}, clientHeader())
+	require.Equal(t, http.Header{"Accept": {"image/*"}, "X-Service": {"previewer"}}, out)
+}
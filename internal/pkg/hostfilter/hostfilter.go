@@ -0,0 +1,153 @@
+package hostfilter
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotAllowed is returned when the host of a URL isn't allowed by the filter.
+var ErrNotAllowed = errors.New("host is not allowed")
+
+// Pattern is a parsed host pattern. Empty port matches any port.
+type Pattern struct {
+	host     string
+	wildcard bool
+	port     string
+}
+
+// ParsePattern parses a host pattern: "example.com", "*.example.com" or either of them with a port,
+// like "example.com:8080". The "*." prefix matches any subdomain but not the domain itself.
+func ParsePattern(s string) (Pattern, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	host, port := s, ""
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		if _, err = strconv.ParseUint(p, 10, 16); err != nil {
+			return Pattern{}, errors.Errorf("[hostfilter::ParsePattern]: invalid port in %q", s)
+		}
+		host, port = h, p
+	}
+
+	wildcard := false
+	if rest, ok := strings.CutPrefix(host, "*."); ok {
+		host, wildcard = rest, true
+	}
+
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || strings.Contains(host, "*") {
+		return Pattern{}, errors.Errorf("[hostfilter::ParsePattern]: invalid pattern %q", s)
+	}
+
+	return Pattern{host: host, wildcard: wildcard, port: port}, nil
+}
+
+// Match reports whether the pattern matches the host of the URL. URLs without an explicit port
+// are matched against the default port of the scheme.
+func (p Pattern) Match(u *url.URL) bool {
+	host, port := hostPort(u)
+
+	return p.match(host, port)
+}
+
+// match reports whether the pattern matches the host and port.
+func (p Pattern) match(host, port string) bool {
+	if p.port != "" && p.port != port {
+		return false
+	}
+
+	if p.wildcard {
+		return strings.HasSuffix(host, "."+p.host)
+	}
+
+	return host == p.host
+}
+
+// Filter decides which hosts may be requested. Denied hosts take precedence over allowed ones.
+// Filter without allowed hosts allows every host that isn't denied.
+type Filter struct {
+	allowed []Pattern
+	denied  []Pattern
+}
+
+// NewFilter creates a filter from the lists of allowed and denied host patterns.
+func NewFilter(allowed, denied []string) (*Filter, error) {
+	f := &Filter{}
+
+	for _, s := range allowed {
+		p, err := ParsePattern(s)
+		if err != nil {
+			return nil, err
+		}
+		f.allowed = append(f.allowed, p)
+	}
+
+	for _, s := range denied {
+		p, err := ParsePattern(s)
+		if err != nil {
+			return nil, err
+		}
+		f.denied = append(f.denied, p)
+	}
+
+	return f, nil
+}
+
+// Check returns ErrNotAllowed if the host of the URL isn't allowed. URLs without an explicit port
+// are matched against the default port of the scheme.
+func (f *Filter) Check(u *url.URL) error {
+	host, port := hostPort(u)
+
+	for _, p := range f.denied {
+		if p.match(host, port) {
+			return errors.Wrapf(ErrNotAllowed, "[hostfilter::Check]: %v is denied", u.Host)
+		}
+	}
+
+	if len(f.allowed) == 0 {
+		return nil
+	}
+
+	for _, p := range f.allowed {
+		if p.match(host, port) {
+			return nil
+		}
+	}
+
+	return errors.Wrapf(ErrNotAllowed, "[hostfilter::Check]: %v is not in the allowed list", u.Host)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying the filter. It's used to restrict the hosts
+// requested on behalf of a single request in addition to the filters applying to all requests.
+func NewContext(ctx context.Context, f *Filter) context.Context {
+	return context.WithValue(ctx, contextKey{}, f)
+}
+
+// FromContext returns the filter carried by the context.
+func FromContext(ctx context.Context) (*Filter, bool) {
+	f, ok := ctx.Value(contextKey{}).(*Filter)
+	return f, ok
+}
+
+// hostPort returns the normalized host of the URL and its port, the default port of the scheme if it's omitted.
+func hostPort(u *url.URL) (string, string) {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+
+	return host, port
+}
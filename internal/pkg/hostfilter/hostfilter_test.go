@@ -0,0 +1,64 @@
+package hostfilter
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	f, err := NewFilter(
+		[]string{"example.com", "*.cdn.example.com", "images.test:8080", "*.static.test:443"},
+		[]string{"private.cdn.example.com", "example.com:8443"},
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{url: "http://example.com/a.jpg", allowed: true},
+		{url: "https://EXAMPLE.com./a.jpg", allowed: true},
+		{url: "https://example.com:8443/a.jpg", allowed: false},
+		{url: "http://img.cdn.example.com/a.jpg", allowed: true},
+		{url: "http://a.b.cdn.example.com/a.jpg", allowed: true},
+		{url: "http://cdn.example.com/a.jpg", allowed: false},
+		{url: "http://private.cdn.example.com/a.jpg", allowed: false},
+		{url: "http://evilexample.com/a.jpg", allowed: false},
+		{url: "http://images.test:8080/a.jpg", allowed: true},
+		{url: "http://images.test/a.jpg", allowed: false},
+		{url: "https://a.static.test/a.jpg", allowed: true},
+		{url: "http://a.static.test/a.jpg", allowed: false},
+		{url: "http://other.org/a.jpg", allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			require.NoError(t, err)
+
+			if tt.allowed {
+				require.NoError(t, f.Check(u))
+			} else {
+				require.ErrorIs(t, f.Check(u), ErrNotAllowed)
+			}
+		})
+	}
+}
+
+func TestCheckDenyOnly(t *testing.T) {
+	f, err := NewFilter(nil, []string{"*.example.com"})
+	require.NoError(t, err)
+
+	require.NoError(t, f.Check(&url.URL{Scheme: "http", Host: "other.org"}))
+	require.NoError(t, f.Check(&url.URL{Scheme: "http", Host: "example.com"}))
+	require.ErrorIs(t, f.Check(&url.URL{Scheme: "http", Host: "a.example.com"}), ErrNotAllowed)
+}
+
+func TestNewFilterInvalidPattern(t *testing.T) {
+	for _, p := range []string{"", "*", "a.*.com", "example.com:http", "example.com:70000"} {
+		_, err := NewFilter([]string{p}, nil)
+		require.Error(t, err, p)
+	}
+}
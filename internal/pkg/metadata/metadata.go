@@ -0,0 +1,474 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	jpegMarkerSOI   = 0xD8
+	jpegMarkerSOS   = 0xDA
+	jpegMarkerEOI   = 0xD9
+	jpegMarkerAPP1  = 0xE1
+	jpegMarkerAPP2  = 0xE2
+	jpegMarkerAPP14 = 0xEE
+	jpegMarkerAPP15 = 0xEF
+	jpegMarkerCOM   = 0xFE
+
+	exifTagOrientation = 0x0112
+
+	// maxICCChunk is the maximum ICC payload per APP2 segment:
+	// 65535 (max segment length) - 2 (length field) - 14 (ICC_PROFILE header).
+	maxICCChunk = 65519
+
+	// maxICCSize is the maximum size of an extracted ICC profile. Real profiles are at most a few hundred
+	// kilobytes, larger compressed ones are treated as missing, so they can't be inflated without a limit.
+	maxICCSize = 4 << 20
+)
+
+var (
+	// ErrUnsupportedFormat is returned when metadata can't be handled for the given image format.
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+
+	// ErrMalformed is returned when the encoded image can't be parsed.
+	ErrMalformed = errors.New("malformed image data")
+
+	iccSignature  = []byte("ICC_PROFILE\x00")
+	exifSignature = []byte("Exif\x00\x00")
+	pngSignature  = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// ExtractICC returns the embedded ICC profile of JPEG or PNG encoded data.
+// It returns nil if the image doesn't carry a profile or can't be parsed.
+func ExtractICC(data []byte, format string) []byte {
+	switch format {
+	case "jpeg", "jpg":
+		return extractJPEGICC(data)
+	case "png":
+		return extractPNGICC(data)
+	default:
+		return nil
+	}
+}
+
+// EmbedICC inserts the ICC profile into JPEG or PNG encoded data produced by the standard library encoders.
+func EmbedICC(data []byte, format string, icc []byte) ([]byte, error) {
+	if len(icc) == 0 {
+		return data, nil
+	}
+
+	switch format {
+	case "jpeg", "jpg":
+		return embedJPEGICC(data, icc)
+	case "png":
+		return embedPNGICC(data, icc)
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedFormat, "[metadata::EmbedICC]: %q", format)
+	}
+}
+
+// jpegSegment describes a marker segment of a JPEG stream. Offset points to the 0xFF byte of the marker.
+type jpegSegment struct {
+	marker  byte
+	offset  int
+	payload []byte
+}
+
+// jpegSegments walks JPEG marker segments up to the start of scan.
+// It returns the offset of the start of scan marker if the walk wasn't stopped by fn.
+func jpegSegments(data []byte, fn func(seg jpegSegment) bool) (int, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != jpegMarkerSOI {
+		return 0, ErrMalformed
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 0, ErrMalformed
+		}
+
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+
+		if marker == jpegMarkerEOI || marker == jpegMarkerSOS {
+			return pos, nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return 0, ErrMalformed
+		}
+
+		if !fn(jpegSegment{marker: marker, offset: pos, payload: data[pos+4 : pos+2+length]}) {
+			return pos, nil
+		}
+
+		pos += 2 + length
+	}
+
+	return 0, ErrMalformed
+}
+
+func extractJPEGICC(data []byte) []byte {
+	chunks := make(map[int][]byte)
+	total := 0
+
+	_, err := jpegSegments(data, func(seg jpegSegment) bool {
+		if seg.marker != jpegMarkerAPP2 || !bytes.HasPrefix(seg.payload, iccSignature) || len(seg.payload) < 14 {
+			return true
+		}
+
+		seq, count := int(seg.payload[12]), int(seg.payload[13])
+		if seq == 0 || seq > count {
+			return true
+		}
+
+		chunks[seq] = seg.payload[14:]
+		total = count
+
+		return true
+	})
+	if err != nil || total == 0 || len(chunks) != total {
+		return nil
+	}
+
+	var icc []byte
+	for i := 1; i <= total; i++ {
+		chunk, ok := chunks[i]
+		if !ok {
+			return nil
+		}
+		icc = append(icc, chunk...)
+	}
+
+	return icc
+}
+
+func embedJPEGICC(data []byte, icc []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != jpegMarkerSOI {
+		return nil, errors.Wrap(ErrMalformed, "[metadata::embedJPEGICC]")
+	}
+
+	count := (len(icc) + maxICCChunk - 1) / maxICCChunk
+	if count > 255 {
+		return nil, errors.New("[metadata::embedJPEGICC]: ICC profile is too large")
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(icc) + count*18)
+	buf.Write(data[:2])
+
+	for i := 0; i < count; i++ {
+		chunk := icc[i*maxICCChunk : min((i+1)*maxICCChunk, len(icc))]
+
+		buf.Write([]byte{0xFF, jpegMarkerAPP2})
+		_ = binary.Write(&buf, binary.BigEndian, uint16(2+len(iccSignature)+2+len(chunk))) //nolint:gosec
+		buf.Write(iccSignature)
+		buf.Write([]byte{byte(i + 1), byte(count)})
+		buf.Write(chunk)
+	}
+
+	buf.Write(data[2:])
+
+	return buf.Bytes(), nil
+}
+
+// pngChunk describes a chunk of a PNG stream. Offset points to the length field of the chunk.
+type pngChunk struct {
+	typ    string
+	offset int
+	data   []byte
+}
+
+// pngChunks walks PNG chunks until IEND.
+func pngChunks(data []byte, fn func(chunk pngChunk) bool) error {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return ErrMalformed
+	}
+
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		if length < 0 || pos+12+length > len(data) {
+			return ErrMalformed
+		}
+
+		chunk := pngChunk{typ: string(data[pos+4 : pos+8]), offset: pos, data: data[pos+8 : pos+8+length]}
+		if !fn(chunk) || chunk.typ == "IEND" {
+			return nil
+		}
+
+		pos += 12 + length
+	}
+
+	return ErrMalformed
+}
+
+func extractPNGICC(data []byte) []byte {
+	var icc []byte
+
+	_ = pngChunks(data, func(chunk pngChunk) bool {
+		if chunk.typ != "iCCP" {
+			return chunk.typ != "IDAT"
+		}
+
+		// Profile name (1-79 bytes), null separator, compression method, compressed profile.
+		sep := bytes.IndexByte(chunk.data, 0)
+		if sep < 1 || sep+2 > len(chunk.data) || chunk.data[sep+1] != 0 {
+			return false
+		}
+
+		zr, err := zlib.NewReader(bytes.NewReader(chunk.data[sep+2:]))
+		if err != nil {
+			return false
+		}
+		defer zr.Close()
+
+		profile, err := io.ReadAll(io.LimitReader(zr, maxICCSize+1))
+		if err != nil || len(profile) > maxICCSize {
+			return false
+		}
+
+		icc = profile
+
+		return false
+	})
+
+	return icc
+}
+
+func embedPNGICC(data []byte, icc []byte) ([]byte, error) {
+	insertAt := -1
+
+	err := pngChunks(data, func(chunk pngChunk) bool {
+		if chunk.typ == "IHDR" {
+			insertAt = chunk.offset + 12 + len(chunk.data)
+		}
+		return false
+	})
+	if err != nil || insertAt < 0 {
+		return nil, errors.Wrap(ErrMalformed, "[metadata::embedPNGICC]")
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err = zw.Write(icc); err != nil {
+		return nil, errors.Wrap(err, "[metadata::embedPNGICC]: failed to compress ICC profile")
+	}
+	if err = zw.Close(); err != nil {
+		return nil, errors.Wrap(err, "[metadata::embedPNGICC]: failed to compress ICC profile")
+	}
+
+	payload := append([]byte("ICC Profile\x00\x00"), compressed.Bytes()...)
+
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(payload) + 12)
+	buf.Write(data[:insertAt])
+	writePNGChunk(&buf, "iCCP", payload)
+	buf.Write(data[insertAt:])
+
+	return buf.Bytes(), nil
+}
+
+func writePNGChunk(buf *bytes.Buffer, typ string, payload []byte) {
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(payload))) //nolint:gosec
+
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write([]byte(typ))
+	_, _ = crc.Write(payload)
+
+	buf.WriteString(typ)
+	buf.Write(payload)
+	_ = binary.Write(buf, binary.BigEndian, crc.Sum32())
+}
+
+// Orientation returns the EXIF orientation (1-8) of JPEG or PNG encoded data.
+// It returns 1 (the default orientation) if the image doesn't carry EXIF data.
+func Orientation(data []byte, format string) int {
+	var exif []byte
+
+	switch format {
+	case "jpeg", "jpg":
+		_, _ = jpegSegments(data, func(seg jpegSegment) bool {
+			if seg.marker == jpegMarkerAPP1 && bytes.HasPrefix(seg.payload, exifSignature) {
+				exif = seg.payload[len(exifSignature):]
+				return false
+			}
+			return true
+		})
+	case "png":
+		_ = pngChunks(data, func(chunk pngChunk) bool {
+			if chunk.typ == "eXIf" {
+				exif = chunk.data
+				return false
+			}
+			return chunk.typ != "IDAT"
+		})
+	}
+
+	if orientation := exifOrientation(exif); orientation >= 1 && orientation <= 8 {
+		return orientation
+	}
+
+	return 1
+}
+
+// exifOrientation looks up the orientation tag in the first IFD of a TIFF structure.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+
+		if order.Uint16(tiff[entry:]) == exifTagOrientation {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 0
+}
+
+// FrameCount returns the number of frames of JPEG or PNG encoded data. Animated PNGs report
+// the number of frames from their animation control chunk, everything else has a single frame.
+func FrameCount(data []byte, format string) int {
+	frames := 1
+
+	if format == "png" {
+		_ = pngChunks(data, func(chunk pngChunk) bool {
+			if chunk.typ == "acTL" && len(chunk.data) >= 4 {
+				frames = max(int(binary.BigEndian.Uint32(chunk.data)), 1)
+				return false
+			}
+			return chunk.typ != "IDAT"
+		})
+	}
+
+	return frames
+}
+
+// Strip removes metadata from JPEG or PNG encoded data without re-encoding the image:
+// EXIF, XMP, comments and textual chunks are always dropped, the ICC profile is kept only if keepICC is set.
+// Segments affecting decoding (JFIF, Adobe color transform) are preserved.
+func Strip(data []byte, format string, keepICC bool) ([]byte, error) {
+	switch format {
+	case "jpeg", "jpg":
+		return stripJPEG(data, keepICC)
+	case "png":
+		return stripPNG(data, keepICC)
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedFormat, "[metadata::Strip]: %q", format)
+	}
+}
+
+func stripJPEG(data []byte, keepICC bool) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	buf.Write(data[:min(len(data), 2)])
+
+	scan, err := jpegSegments(data, func(seg jpegSegment) bool {
+		var drop bool
+
+		switch {
+		case seg.marker == jpegMarkerCOM:
+			drop = true
+		case seg.marker == jpegMarkerAPP2 && bytes.HasPrefix(seg.payload, iccSignature):
+			drop = !keepICC
+		case seg.marker >= jpegMarkerAPP1 && seg.marker <= jpegMarkerAPP15:
+			drop = seg.marker != jpegMarkerAPP14
+		}
+
+		if !drop {
+			buf.Write(data[seg.offset : seg.offset+4+len(seg.payload)])
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "[metadata::stripJPEG]")
+	}
+
+	// Trailing data like the secondary images of MPF carries metadata of its own, so it's dropped.
+	buf.Write(data[scan:jpegEnd(data, scan)])
+
+	return buf.Bytes(), nil
+}
+
+// jpegEnd returns the offset following the end of image marker, walking the scans from the start of scan marker
+// at the offset. It returns the length of data if the image isn't terminated.
+func jpegEnd(data []byte, pos int) int {
+	for pos+1 < len(data) {
+		if data[pos] != 0xFF {
+			pos++
+			continue
+		}
+
+		switch marker := data[pos+1]; {
+		case marker == jpegMarkerEOI:
+			return pos + 2
+		case marker == 0x00 || marker == 0xFF || (marker >= 0xD0 && marker <= 0xD7):
+			// Stuffed zero byte, fill byte or restart marker of entropy-coded data.
+			pos++
+		case pos+4 > len(data):
+			return len(data)
+		default:
+			// Segments between the scans of progressive images, including the start of scan ones.
+			pos += 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		}
+	}
+
+	return len(data)
+}
+
+func stripPNG(data []byte, keepICC bool) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	buf.Write(pngSignature)
+
+	err := pngChunks(data, func(chunk pngChunk) bool {
+		switch chunk.typ {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+		case "iCCP":
+			if keepICC {
+				buf.Write(data[chunk.offset : chunk.offset+12+len(chunk.data)])
+			}
+		default:
+			buf.Write(data[chunk.offset : chunk.offset+12+len(chunk.data)])
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "[metadata::stripPNG]")
+	}
+
+	return buf.Bytes(), nil
+}
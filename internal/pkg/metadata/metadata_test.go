@@ -0,0 +1,197 @@
+package metadata
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 8; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 16), G: uint8(y * 32), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func TestICC(t *testing.T) {
+	small := bytes.Repeat([]byte("profile"), 100)
+	large := bytes.Repeat([]byte{1, 2, 3, 4, 5}, 30000) // spans several APP2 segments
+
+	t.Run("jpeg round trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, jpeg.Encode(&buf, testImage(), nil))
+		require.Nil(t, ExtractICC(buf.Bytes(), "jpeg"))
+
+		for _, icc := range [][]byte{small, large} {
+			data, err := EmbedICC(buf.Bytes(), "jpeg", icc)
+			require.NoError(t, err)
+			require.Equal(t, icc, ExtractICC(data, "jpeg"))
+
+			img, err := jpeg.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			require.Equal(t, testImage().Bounds(), img.Bounds())
+		}
+	})
+
+	t.Run("png round trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, testImage()))
+		require.Nil(t, ExtractICC(buf.Bytes(), "png"))
+
+		for _, icc := range [][]byte{small, large} {
+			data, err := EmbedICC(buf.Bytes(), "png", icc)
+			require.NoError(t, err)
+			require.Equal(t, icc, ExtractICC(data, "png"))
+
+			img, err := png.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			require.Equal(t, testImage().Bounds(), img.Bounds())
+		}
+	})
+
+	t.Run("png profile over the limit is ignored", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, testImage()))
+
+		// Compresses to a few kilobytes, but inflates to more than the limit.
+		data, err := EmbedICC(buf.Bytes(), "png", make([]byte, maxICCSize+1))
+		require.NoError(t, err)
+		require.Less(t, len(data), 64<<10)
+		require.Nil(t, ExtractICC(data, "png"))
+	})
+
+	t.Run("empty profile is a no-op", func(t *testing.T) {
+		data := []byte("anything")
+
+		out, err := EmbedICC(data, "gif", nil)
+		require.NoError(t, err)
+		require.Equal(t, data, out)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := EmbedICC([]byte("GIF89a"), "gif", small)
+		require.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+
+	t.Run("malformed data", func(t *testing.T) {
+		require.Nil(t, ExtractICC([]byte("not an image"), "jpeg"))
+		require.Nil(t, ExtractICC([]byte("not an image"), "png"))
+
+		_, err := EmbedICC([]byte("not an image"), "png", small)
+		require.ErrorIs(t, err, ErrMalformed)
+	})
+}
+
+func TestOrientation(t *testing.T) {
+	// Big-endian TIFF header with a single IFD entry: orientation (SHORT) = 6.
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08,
+		0x00, 0x01,
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x06, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, testImage(), nil))
+	require.Equal(t, 1, Orientation(buf.Bytes(), "jpeg"))
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := append([]byte{0xFF, 0xE1, 0x00, byte(len(payload) + 2)}, payload...)
+	data := append(append(append([]byte{}, buf.Bytes()[:2]...), segment...), buf.Bytes()[2:]...)
+
+	require.Equal(t, 6, Orientation(data, "jpeg"))
+	require.Equal(t, 1, Orientation([]byte("not an image"), "jpeg"))
+}
+
+func TestFrameCount(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, testImage()))
+	require.Equal(t, 1, FrameCount(buf.Bytes(), "png"))
+
+	var apng bytes.Buffer
+	apng.Write(buf.Bytes()[:33]) // signature and IHDR
+	writePNGChunk(&apng, "acTL", []byte{0, 0, 0, 3, 0, 0, 0, 0})
+	apng.Write(buf.Bytes()[33:])
+
+	require.Equal(t, 3, FrameCount(apng.Bytes(), "png"))
+	require.Equal(t, 1, FrameCount(apng.Bytes(), "jpeg"))
+}
+
+func TestStrip(t *testing.T) {
+	icc := []byte("profile")
+
+	t.Run("jpeg", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, jpeg.Encode(&buf, testImage(), nil))
+
+		withICC, err := EmbedICC(buf.Bytes(), "jpeg", icc)
+		require.NoError(t, err)
+
+		exif := append([]byte("Exif\x00\x00"), []byte("MM\x00\x2A\x00\x00\x00\x08\x00\x00")...)
+		comment := []byte("secret")
+
+		var src bytes.Buffer
+		src.Write(withICC[:2])
+		src.Write([]byte{0xFF, 0xE1, 0x00, byte(len(exif) + 2)})
+		src.Write(exif)
+		src.Write([]byte{0xFF, 0xFE, 0x00, byte(len(comment) + 2)})
+		src.Write(comment)
+		src.Write(withICC[2:])
+
+		stripped, err := Strip(src.Bytes(), "jpeg", false)
+		require.NoError(t, err)
+		require.Equal(t, buf.Bytes(), stripped)
+
+		kept, err := Strip(src.Bytes(), "jpeg", true)
+		require.NoError(t, err)
+		require.Equal(t, withICC, kept)
+
+		t.Run("trailing image", func(t *testing.T) {
+			// A secondary image appended after the end of image the way MPF does, with EXIF of its own.
+			withTrailer := append(bytes.Clone(src.Bytes()), src.Bytes()...)
+
+			stripped, err := Strip(withTrailer, "jpeg", false)
+			require.NoError(t, err)
+			require.Equal(t, buf.Bytes(), stripped)
+			require.NotContains(t, string(stripped), "Exif")
+		})
+	})
+
+	t.Run("png", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, testImage()))
+
+		withICC, err := EmbedICC(buf.Bytes(), "png", icc)
+		require.NoError(t, err)
+
+		var src bytes.Buffer
+		src.Write(withICC[:33])
+		writePNGChunk(&src, "tEXt", []byte("Comment\x00secret"))
+		writePNGChunk(&src, "eXIf", []byte("MM\x00\x2A\x00\x00\x00\x08\x00\x00"))
+		src.Write(withICC[33:])
+
+		stripped, err := Strip(src.Bytes(), "png", false)
+		require.NoError(t, err)
+		require.Equal(t, buf.Bytes(), stripped)
+
+		kept, err := Strip(src.Bytes(), "png", true)
+		require.NoError(t, err)
+		require.Equal(t, withICC, kept)
+	})
+
+	t.Run("malformed data", func(t *testing.T) {
+		_, err := Strip([]byte("not an image"), "jpeg", false)
+		require.ErrorIs(t, err, ErrMalformed)
+
+		_, err = Strip([]byte("not an image"), "gif", false)
+		require.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+}
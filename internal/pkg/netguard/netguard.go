@@ -0,0 +1,192 @@
+package netguard
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBlocked is returned when a connection to a blocked address is attempted.
+var ErrBlocked = errors.New("address is blocked")
+
+var (
+	// privateNetworks are the networks that aren't publicly routable.
+	privateNetworks = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+		netip.MustParsePrefix("10.0.0.0/8"),      // private
+		netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+		netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+		netip.MustParsePrefix("169.254.0.0/16"),  // link-local
+		netip.MustParsePrefix("172.16.0.0/12"),   // private
+		netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+		netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+		netip.MustParsePrefix("192.168.0.0/16"),  // private
+		netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+		netip.MustParsePrefix("198.51.100.0/24"), // documentation
+		netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+		netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+		netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+		netip.MustParsePrefix("::/128"),          // unspecified
+		netip.MustParsePrefix("::1/128"),         // loopback
+		netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+		netip.MustParsePrefix("100::/64"),        // discard-only
+		netip.MustParsePrefix("2001:db8::/32"),   // documentation
+		netip.MustParsePrefix("fc00::/7"),        // unique local
+		netip.MustParsePrefix("fe80::/10"),       // link-local
+		netip.MustParsePrefix("ff00::/8"),        // multicast
+	}
+
+	nat64      = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFour  = netip.MustParsePrefix("2002::/16")
+	ipv4Compat = netip.MustParsePrefix("::/96")
+)
+
+// Guard decides which IP addresses outgoing connections may be made to. It's meant to be used
+// as the Control function of a net.Dialer, so addresses are checked after DNS resolution
+// for every connection, including the ones made when following redirects.
+type Guard struct {
+	blockPrivate bool
+	blocked      []netip.Prefix
+	allowed      []netip.Prefix
+}
+
+// Option configures a Guard.
+type Option func(g *Guard)
+
+// WithPrivateNetworks makes the guard allow the addresses that aren't publicly routable, like loopback,
+// link-local and private ones, that are blocked by default.
+func WithPrivateNetworks() Option {
+	return func(g *Guard) {
+		g.blockPrivate = false
+	}
+}
+
+// WithBlocked blocks the networks in addition to the private ones.
+func WithBlocked(networks ...netip.Prefix) Option {
+	return func(g *Guard) {
+		g.blocked = append(g.blocked, networks...)
+	}
+}
+
+// WithAllowed allows the networks even if they are blocked otherwise.
+func WithAllowed(networks ...netip.Prefix) Option {
+	return func(g *Guard) {
+		g.allowed = append(g.allowed, networks...)
+	}
+}
+
+// NewGuard creates a guard blocking the addresses that aren't publicly routable.
+func NewGuard(opts ...Option) *Guard {
+	g := &Guard{blockPrivate: true}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+// ParsePrefixes parses networks in CIDR notation. A single address is treated as a network of one address.
+func ParsePrefixes(networks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(networks))
+
+	for _, network := range networks {
+		network = strings.TrimSpace(network)
+
+		if !strings.Contains(network, "/") {
+			addr, err := netip.ParseAddr(network)
+			if err != nil {
+				return nil, errors.Wrapf(err, "[netguard::ParsePrefixes]: invalid network %q", network)
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[netguard::ParsePrefixes]: invalid network %q", network)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// Allowed reports whether connections to the address are allowed. IPv6 addresses embedding an IPv4 one
+// are only allowed if the embedded address is allowed as well.
+func (g *Guard) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+
+	if contains(g.allowed, addr) {
+		return true
+	}
+
+	if v4, ok := embeddedIPv4(addr); ok && !g.Allowed(v4) {
+		return false
+	}
+
+	if g.blockPrivate && isPrivate(addr) {
+		return false
+	}
+
+	return !contains(g.blocked, addr)
+}
+
+// Control checks the address a dialer is about to connect to. It has the signature of net.Dialer.Control.
+func (g *Guard) Control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return errors.Wrapf(ErrBlocked, "[netguard::Control]: can't parse address %q", address)
+	}
+
+	if !g.Allowed(addrPort.Addr()) {
+		return errors.Wrapf(ErrBlocked, "[netguard::Control]: %v", addrPort.Addr())
+	}
+
+	return nil
+}
+
+// Dialer returns a dialer checking every connection with the guard. Timeouts are the ones of http.DefaultTransport.
+func (g *Guard) Dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   g.Control,
+	}
+}
+
+// isPrivate reports whether the address isn't publicly routable.
+func isPrivate(addr netip.Addr) bool {
+	return contains(privateNetworks, addr)
+}
+
+// embeddedIPv4 returns the IPv4 address embedded into the IPv6 one by NAT64, 6to4
+// or the deprecated IPv4-compatible format.
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	b := addr.As16()
+
+	switch {
+	case nat64.Contains(addr), ipv4Compat.Contains(addr):
+		return netip.AddrFrom4([4]byte(b[12:16])), true
+	case sixToFour.Contains(addr):
+		return netip.AddrFrom4([4]byte(b[2:6])), true
+	default:
+		return netip.Addr{}, false
+	}
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,124 @@
+package netguard
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowed(t *testing.T) {
+	blocked, err := ParsePrefixes([]string{"93.184.217.0/24", "1.1.1.1"})
+	require.NoError(t, err)
+
+	allowed, err := ParsePrefixes([]string{"10.1.0.0/16"})
+	require.NoError(t, err)
+
+	g := NewGuard(WithBlocked(blocked...), WithAllowed(allowed...))
+
+	tests := []struct {
+		addr    string
+		allowed bool
+	}{
+		{addr: "93.184.216.34", allowed: true},
+		{addr: "2606:2800:220:1::1", allowed: true},
+		{addr: "127.0.0.1", allowed: false},
+		{addr: "::1", allowed: false},
+		{addr: "::ffff:127.0.0.1", allowed: false},
+		{addr: "0.0.0.0", allowed: false},
+		{addr: "169.254.169.254", allowed: false},
+		{addr: "fe80::1", allowed: false},
+		{addr: "10.0.0.1", allowed: false},
+		{addr: "172.16.5.4", allowed: false},
+		{addr: "192.168.1.1", allowed: false},
+		{addr: "fd00::1", allowed: false},
+		{addr: "fe80::1%eth0", allowed: false},
+		{addr: "0.1.2.3", allowed: false},
+		{addr: "100.64.0.1", allowed: false},
+		{addr: "198.18.0.1", allowed: false},
+		{addr: "224.0.0.1", allowed: false},
+		{addr: "255.255.255.255", allowed: false},
+		{addr: "ff02::1", allowed: false},
+		{addr: "64:ff9b::7f00:1", allowed: false},
+		{addr: "64:ff9b::a9fe:a9fe", allowed: false},
+		{addr: "64:ff9b::5db8:d822", allowed: true},
+		{addr: "2002:c0a8:101::1", allowed: false},
+		{addr: "2002:5db8:d822::1", allowed: true},
+		{addr: "64:ff9b::5db8:d90a", allowed: false},
+		{addr: "64:ff9b::a01:203", allowed: true},
+		{addr: "192.0.2.1", allowed: false},
+		{addr: "198.51.100.8", allowed: false},
+		{addr: "203.0.113.10", allowed: false},
+		{addr: "2001:db8::1", allowed: false},
+		{addr: "::7f00:1", allowed: false},
+		{addr: "::a9fe:a9fe", allowed: false},
+		{addr: "::5db8:d822", allowed: true},
+		{addr: "93.184.217.10", allowed: false},
+		{addr: "1.1.1.1", allowed: false},
+		{addr: "1.1.1.2", allowed: true},
+		{addr: "10.1.2.3", allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			require.Equal(t, tt.allowed, g.Allowed(netip.MustParseAddr(tt.addr)))
+		})
+	}
+
+	t.Run("private networks allowed", func(t *testing.T) {
+		g := NewGuard(WithPrivateNetworks(), WithBlocked(blocked...))
+		require.True(t, g.Allowed(netip.MustParseAddr("127.0.0.1")))
+		require.False(t, g.Allowed(netip.MustParseAddr("93.184.217.10")))
+	})
+}
+
+func TestParsePrefixes(t *testing.T) {
+	prefixes, err := ParsePrefixes([]string{"10.0.0.1/8", " ::1 "})
+	require.NoError(t, err)
+	require.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")}, prefixes)
+
+	_, err = ParsePrefixes([]string{"example.com"})
+	require.Error(t, err)
+}
+
+func TestDialer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := func(g *Guard) *http.Client {
+		return &http.Client{Transport: &http.Transport{DialContext: g.Dialer().DialContext}}
+	}
+
+	resp, err := client(NewGuard()).Get(srv.URL)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	require.ErrorIs(t, err, ErrBlocked)
+
+	resp, err = client(NewGuard(WithPrivateNetworks())).Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	t.Run("redirect to blocked address", func(t *testing.T) {
+		// The first hop is allowed explicitly, the redirect target is a different loopback address.
+		redirect := httptest.NewServer(http.RedirectHandler("http://127.0.0.2:1/", http.StatusFound))
+		defer redirect.Close()
+
+		g := NewGuard(WithAllowed(netip.MustParsePrefix("127.0.0.1/32")))
+
+		_, err := (&net.Dialer{Control: g.Control}).DialContext(context.Background(), "tcp", "127.0.0.2:1")
+		require.ErrorIs(t, err, ErrBlocked)
+
+		resp, err := client(g).Get(redirect.URL)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		require.ErrorIs(t, err, ErrBlocked)
+	})
+}
@@ -0,0 +1,89 @@
+package packing
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ErrDoesNotFit is returned when a rectangle is wider than the maximum width of the bin.
+var ErrDoesNotFit = errors.New("rectangle doesn't fit into the bin")
+
+// shelf is a row of the packed bin. All rectangles of a shelf share its top edge.
+type shelf struct {
+	y      int
+	height int
+	used   int
+}
+
+// Pack places rectangles of the given sizes into a single bin using the first fit decreasing height
+// shelf algorithm. Rectangles are separated by padding. The bin width targets a square layout
+// but never exceeds maxWidth. It returns the top left corner of every rectangle in the order
+// of sizes and the size of the bin.
+func Pack(sizes []image.Point, padding, maxWidth int) ([]image.Point, image.Point, error) {
+	if len(sizes) == 0 {
+		return nil, image.Point{}, nil
+	}
+
+	var (
+		area  int
+		width int
+	)
+
+	for _, s := range sizes {
+		if s.X > maxWidth {
+			return nil, image.Point{}, errors.Wrapf(ErrDoesNotFit, "[packing::Pack]: width %d exceeds %d", s.X, maxWidth)
+		}
+		area += (s.X + padding) * (s.Y + padding)
+		width = max(width, s.X+padding)
+	}
+
+	// Padding is only needed between rectangles, so the bin may be one padding narrower than the shelves.
+	binWidth := min(max(width, int(math.Ceil(math.Sqrt(float64(area))))), maxWidth+padding)
+
+	order := make([]int, len(sizes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return sizes[order[i]].Y > sizes[order[j]].Y
+	})
+
+	var (
+		shelves   []*shelf
+		positions = make([]image.Point, len(sizes))
+		bin       image.Point
+	)
+
+	for _, idx := range order {
+		w, h := sizes[idx].X+padding, sizes[idx].Y+padding
+
+		var target *shelf
+		for _, sh := range shelves {
+			if sh.used+w <= binWidth && h <= sh.height {
+				target = sh
+				break
+			}
+		}
+
+		if target == nil {
+			y := 0
+			if len(shelves) > 0 {
+				last := shelves[len(shelves)-1]
+				y = last.y + last.height
+			}
+			target = &shelf{y: y, height: h}
+			shelves = append(shelves, target)
+		}
+
+		positions[idx] = image.Pt(target.used, target.y)
+		target.used += w
+
+		bin.X = max(bin.X, target.used-padding)
+		bin.Y = max(bin.Y, target.y+h-padding)
+	}
+
+	return positions, bin, nil
+}
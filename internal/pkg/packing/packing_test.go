@@ -0,0 +1,53 @@
+package packing
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPack(t *testing.T) {
+	t.Run("rectangles don't overlap", func(t *testing.T) {
+		sizes := []image.Point{{32, 32}, {64, 16}, {16, 16}, {48, 48}, {10, 70}, {32, 8}, {16, 16}}
+
+		positions, bin, err := Pack(sizes, 2, 1024)
+		require.NoError(t, err)
+		require.Len(t, positions, len(sizes))
+
+		rects := make([]image.Rectangle, len(sizes))
+		for i, p := range positions {
+			rects[i] = image.Rectangle{Min: p, Max: p.Add(sizes[i])}
+			require.True(t, rects[i].In(image.Rectangle{Max: bin}), "rect %v is out of bin %v", rects[i], bin)
+		}
+
+		for i := range rects {
+			for j := i + 1; j < len(rects); j++ {
+				require.False(t, rects[i].Inset(-1).Overlaps(rects[j]), "%v and %v are closer than padding", rects[i], rects[j])
+			}
+		}
+	})
+
+	t.Run("max width is respected", func(t *testing.T) {
+		sizes := make([]image.Point, 20)
+		for i := range sizes {
+			sizes[i] = image.Pt(40, 10)
+		}
+
+		_, bin, err := Pack(sizes, 0, 80)
+		require.NoError(t, err)
+		require.Equal(t, image.Pt(80, 100), bin)
+	})
+
+	t.Run("too wide rectangle", func(t *testing.T) {
+		_, _, err := Pack([]image.Point{{100, 10}}, 0, 50)
+		require.ErrorIs(t, err, ErrDoesNotFit)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		positions, bin, err := Pack(nil, 0, 50)
+		require.NoError(t, err)
+		require.Nil(t, positions)
+		require.Equal(t, image.Point{}, bin)
+	})
+}
@@ -0,0 +1,172 @@
+package phash
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/nfnt/resize"
+	"github.com/pkg/errors"
+)
+
+// Algorithm names supported by Compute.
+const (
+	AHash = "ahash"
+	DHash = "dhash"
+	PHash = "phash"
+)
+
+// ErrUnknownAlgorithm is returned when a hash algorithm isn't supported.
+var ErrUnknownAlgorithm = errors.New("unknown hash algorithm")
+
+// Hash is a 64-bit perceptual hash.
+type Hash uint64
+
+// String formats the hash as 16 hex digits.
+func (h Hash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// Distance returns the Hamming distance between two hashes.
+func Distance(a, b Hash) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// Supported reports whether the named algorithm is supported by Compute.
+func Supported(algo string) bool {
+	return algo == AHash || algo == DHash || algo == PHash
+}
+
+// Compute computes the hash of an image using the named algorithm.
+func Compute(algo string, img image.Image) (Hash, error) {
+	switch algo {
+	case AHash:
+		return Average(img), nil
+	case DHash:
+		return Difference(img), nil
+	case PHash:
+		return Perceptual(img), nil
+	default:
+		return 0, errors.Wrapf(ErrUnknownAlgorithm, "[phash::Compute]: %q", algo)
+	}
+}
+
+// Average computes the average hash: every bit tells whether a pixel of the 8x8 grayscale copy
+// is brighter than the mean.
+func Average(img image.Image) Hash {
+	pixels := grayscale(img, 8, 8)
+
+	var sum float64
+	for _, p := range pixels {
+		sum += p
+	}
+	mean := sum / float64(len(pixels))
+
+	var h Hash
+	for i, p := range pixels {
+		if p > mean {
+			h |= 1 << uint(i)
+		}
+	}
+
+	return h
+}
+
+// Difference computes the difference hash: every bit tells whether a pixel of the 9x8 grayscale copy
+// is brighter than its right neighbour.
+func Difference(img image.Image) Hash {
+	pixels := grayscale(img, 9, 8)
+
+	var h Hash
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if pixels[y*9+x] > pixels[y*9+x+1] {
+				h |= 1 << uint(y*8+x)
+			}
+		}
+	}
+
+	return h
+}
+
+// Perceptual computes the DCT based hash: every bit tells whether a low frequency coefficient
+// of the 32x32 grayscale copy is above the median of the 8x8 lowest frequencies.
+func Perceptual(img image.Image) Hash {
+	const size, lowSize = 32, 8
+
+	pixels := grayscale(img, size, size)
+	coefficients := dct2D(pixels, size)
+
+	low := make([]float64, 0, lowSize*lowSize)
+	for y := 0; y < lowSize; y++ {
+		for x := 0; x < lowSize; x++ {
+			low = append(low, coefficients[y*size+x])
+		}
+	}
+
+	// The DC coefficient only reflects the overall brightness, so it's excluded from the median.
+	sorted := append([]float64{}, low[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var h Hash
+	for i, c := range low {
+		if c > median {
+			h |= 1 << uint(i)
+		}
+	}
+
+	return h
+}
+
+// grayscale downscales an image to width x height and returns its luminance row by row.
+func grayscale(img image.Image, width, height int) []float64 {
+	small := resize.Resize(uint(width), uint(height), img, resize.Bilinear) //nolint:gosec
+	b := small.Bounds()
+
+	pixels := make([]float64, 0, width*height)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			pixels = append(pixels, float64(color.GrayModel.Convert(small.At(x, y)).(color.Gray).Y))
+		}
+	}
+
+	return pixels
+}
+
+// dct2D computes the type-II discrete cosine transform of a size x size matrix.
+func dct2D(pixels []float64, size int) []float64 {
+	cosines := make([]float64, size*size)
+	for k := 0; k < size; k++ {
+		for n := 0; n < size; n++ {
+			cosines[k*size+n] = math.Cos(math.Pi / float64(size) * (float64(n) + 0.5) * float64(k))
+		}
+	}
+
+	rows := make([]float64, size*size)
+	for y := 0; y < size; y++ {
+		for k := 0; k < size; k++ {
+			var sum float64
+			for n := 0; n < size; n++ {
+				sum += pixels[y*size+n] * cosines[k*size+n]
+			}
+			rows[y*size+k] = sum
+		}
+	}
+
+	result := make([]float64, size*size)
+	for x := 0; x < size; x++ {
+		for k := 0; k < size; k++ {
+			var sum float64
+			for n := 0; n < size; n++ {
+				sum += rows[n*size+x] * cosines[k*size+n]
+			}
+			result[k*size+x] = sum
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,62 @@
+package phash
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/nfnt/resize"
+	"github.com/stretchr/testify/require"
+)
+
+// scene draws a smooth horizontal gradient with a bright disc centered at (cx, cy).
+func scene(width, height, cx, cy int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	radius := height / 4
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			v := uint8(x * 200 / width)
+			if dx, dy := x-cx, y-cy; dx*dx+dy*dy < radius*radius {
+				v = 255
+			}
+			img.Set(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+
+	return img
+}
+
+func TestHashes(t *testing.T) {
+	original := scene(256, 128, 64, 64)
+	scaled := resize.Resize(512, 256, original, resize.Bilinear)
+	different := scene(256, 128, 192, 32)
+
+	for _, algo := range []string{AHash, DHash, PHash} {
+		t.Run(algo, func(t *testing.T) {
+			h1, err := Compute(algo, original)
+			require.NoError(t, err)
+
+			h2, err := Compute(algo, scaled)
+			require.NoError(t, err)
+
+			h3, err := Compute(algo, different)
+			require.NoError(t, err)
+
+			require.LessOrEqual(t, Distance(h1, h2), 5)
+			require.Greater(t, Distance(h1, h3), Distance(h1, h2))
+			require.Len(t, h1.String(), 16)
+		})
+	}
+
+	t.Run("unknown algorithm", func(t *testing.T) {
+		_, err := Compute("md5", original)
+		require.ErrorIs(t, err, ErrUnknownAlgorithm)
+	})
+}
+
+func TestDistance(t *testing.T) {
+	require.Equal(t, 0, Distance(0xff, 0xff))
+	require.Equal(t, 64, Distance(0, ^Hash(0)))
+	require.Equal(t, 2, Distance(0b1010, 0b0110))
+}
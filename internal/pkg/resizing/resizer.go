@@ -1,52 +1,494 @@
 package resizing
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"image"
+	_ "image/jpeg" // register jpeg decoder
+	_ "image/png"  // register png decoder
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"sync"
 
+	"github.com/devgomax/image-previewer/internal/pkg/breaker"
+	"github.com/devgomax/image-previewer/internal/pkg/headerpolicy"
+	"github.com/devgomax/image-previewer/internal/pkg/hostfilter"
+	"github.com/devgomax/image-previewer/internal/pkg/metadata"
+	"github.com/devgomax/image-previewer/internal/pkg/netguard"
 	"github.com/nfnt/resize"
 	"github.com/pkg/errors"
 )
 
+// Image is an image fetched from upstream along with the metadata extracted from its source.
+type Image struct {
+	Image  image.Image
+	Format string
+	// ICC is the ICC profile of the source. It's only extracted for the contexts created by NewICCContext.
+	ICC []byte
+	// Raw is the encoded source of the image. It's only set while the image has its source dimensions.
+	Raw []byte
+}
+
+// ErrImageTooLarge is returned when the source image has more pixels than allowed.
+var ErrImageTooLarge = errors.New("image dimensions exceed the limit")
+
+// ErrBodyTooLarge is returned when the upstream response body is larger than allowed.
+var ErrBodyTooLarge = errors.New("response body exceeds the limit")
+
+var (
+	// ErrNotFound is returned when the upstream responds with 404 Not Found or 410 Gone.
+	ErrNotFound = errors.New("image not found")
+	// ErrForbidden is returned when the upstream responds with 401 Unauthorized or 403 Forbidden.
+	ErrForbidden = errors.New("access to image is forbidden")
+	// ErrTimeout is returned when the upstream doesn't respond in time.
+	ErrTimeout = errors.New("upstream timeout")
+	// ErrUnsupportedFormat is returned when the upstream body isn't an image of a supported format.
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	// ErrDecode is returned when the upstream body is an image of a supported format, but it can't be decoded.
+	ErrDecode = errors.New("failed to decode image")
+)
+
+// StatusError is returned when the upstream responds with a status other than 200 OK.
+// Statuses having a meaning of their own unwrap to ErrNotFound, ErrForbidden and ErrTimeout.
+type StatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("received status code %d for %s", e.StatusCode, e.URL)
+}
+
+func (e *StatusError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound, http.StatusGone:
+		return ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusGatewayTimeout:
+		return ErrTimeout
+	default:
+		return nil
+	}
+}
+
+// defaultConcurrency is the default number of images fetched in parallel by FetchImages.
+const defaultConcurrency = 4
+
+// maxRedirects is the number of redirects followed by the client, the same as of http.Client by default.
+const maxRedirects = 10
+
 // Resizer is a utility for resizing images fetched from URLs. It uses the `resize` package to perform the resizing.
 type Resizer struct {
-	client *http.Client
+	client      *http.Client
+	concurrency int
+	maxPixels   int64
+	maxBodySize int64
+	limits      Limits
+	hosts       *hostfilter.Filter
+	headers     *headerpolicy.Policy
+	retry       retryPolicy
+	breaker     *breaker.Breaker
+}
+
+// Option configures a Resizer.
+type Option func(r *Resizer)
+
+// WithConcurrency sets the maximum number of images fetched in parallel by FetchImages.
+func WithConcurrency(n int) Option {
+	return func(r *Resizer) {
+		if n > 0 {
+			r.concurrency = n
+		}
+	}
+}
+
+// WithMaxPixels sets the maximum number of pixels of a source image. Larger images are rejected
+// before they are decoded. Zero means no limit.
+func WithMaxPixels(n int64) Option {
+	return func(r *Resizer) {
+		if n > 0 {
+			r.maxPixels = n
+		}
+	}
+}
+
+// WithMaxBodySize sets the maximum size of an upstream response body in bytes. Zero means no limit.
+func WithMaxBodySize(n int64) Option {
+	return func(r *Resizer) {
+		if n > 0 {
+			r.maxBodySize = n
+		}
+	}
+}
+
+// WithOutputLimits sets the limits of the size of resized images.
+func WithOutputLimits(l Limits) Option {
+	return func(r *Resizer) {
+		r.limits = l
+	}
+}
+
+// WithGuard makes the resizer check the address of every upstream connection with the guard,
+// including the connections made when following redirects. Proxies from the environment are not used,
+// as the guard can't check the addresses a proxy connects to.
+func WithGuard(g *netguard.Guard) Option {
+	return func(r *Resizer) {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = g.Dialer().DialContext
+
+		r.client.Transport = transport
+	}
+}
+
+// WithHostFilter makes the resizer request only the hosts allowed by the filter. The filter is checked
+// before a request is made and on every redirect.
+func WithHostFilter(f *hostfilter.Filter) Option {
+	return func(r *Resizer) {
+		r.hosts = f
+	}
+}
+
+// WithHeaderPolicy sets the policy of forwarding client headers to upstreams.
+func WithHeaderPolicy(p *headerpolicy.Policy) Option {
+	return func(r *Resizer) {
+		r.headers = p
+	}
 }
 
 // NewResizer creates a new instance of Resizer with default HTTP client settings.
-func NewResizer() *Resizer {
-	return &Resizer{
-		client: &http.Client{},
+// By default client headers are forwarded to upstreams except for the hop-by-hop and credential ones.
+func NewResizer(opts ...Option) *Resizer {
+	r := &Resizer{
+		client:      &http.Client{},
+		concurrency: defaultConcurrency,
+		headers:     headerpolicy.NewPolicy(headerpolicy.Rules{}),
+	}
+
+	r.client.CheckRedirect = r.checkRedirect
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// clientHeaderContextKey is the context key of the client headers of a download.
+type clientHeaderContextKey struct{}
+
+// checkRedirect checks the host of every redirect, limiting the number of redirects the way http.Client does by default.
+// Headers are recomputed by the policy for every redirect, as http.Client copies the ones of the previous request,
+// and headers set for the origin must not leak to the hosts it redirects to.
+func (r *Resizer) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.Errorf("stopped after %d redirects", maxRedirects)
 	}
+
+	if err := r.checkHost(req.Context(), req.URL); err != nil {
+		return err
+	}
+
+	header, _ := req.Context().Value(clientHeaderContextKey{}).(http.Header)
+	req.Header = r.headers.Apply(req.URL, header)
+
+	return nil
+}
+
+// checkHost checks the host of the URL against the filter of the resizer and the filter of the request context.
+func (r *Resizer) checkHost(ctx context.Context, u *url.URL) error {
+	if r.hosts != nil {
+		if err := r.hosts.Check(u); err != nil {
+			return err
+		}
+	}
+
+	if f, ok := hostfilter.FromContext(ctx); ok {
+		return f.Check(u)
+	}
+
+	return nil
 }
 
-// GetResizedImage fetches an image from URL and resizes it to the specified dimensions.
-// It returns the resized image as well as the MIME type of the original image.
-func (r *Resizer) GetResizedImage(ctx context.Context, url string, width, height uint, header http.Header) (image.Image, string, error) {
+// CheckSource checks whether the source URL may be fetched without fetching it. It allows applying
+// the restrictions of the request to the images that are served from the cache.
+func (r *Resizer) CheckSource(ctx context.Context, u *url.URL) error {
+	return errors.Wrap(r.checkHost(ctx, u), "[resizing::CheckSource]")
+}
+
+// Fetch downloads the raw image data from URL. Responses declaring a body larger than the limit are rejected
+// up front, otherwise the download is aborted as soon as the limit is exceeded. Failed downloads
+// are retried and failing hosts are cut off as configured, see WithRetries and WithCircuitBreaker.
+func (r *Resizer) Fetch(ctx context.Context, url string, header http.Header) ([]byte, error) {
+	ctx = context.WithValue(ctx, clientHeaderContextKey{}, header)
+
 	imgReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, "", errors.Wrap(err, "[resizing::GetResizedImage]: can't create new request")
+		return nil, errors.Wrap(err, "[resizing::Fetch]: can't create new request")
+	}
+
+	if err = r.checkHost(ctx, imgReq.URL); err != nil {
+		return nil, errors.Wrap(err, "[resizing::Fetch]")
 	}
 
-	imgReq.Header = header
+	imgReq.Header = r.headers.Apply(imgReq.URL, header)
 
-	resp, err := r.client.Do(imgReq)
+	return r.fetchWithRetries(imgReq)
+}
+
+// fetch makes a single request and reads the response body.
+func (r *Resizer) fetch(req *http.Request) ([]byte, error) {
+	url := req.URL.String()
+
+	resp, err := r.client.Do(req)
 	if err != nil {
-		return nil, "", errors.Wrapf(err, "[resizing::GetResizedImage]: failed to make request to %v", url)
+		if isTimeout(err) {
+			return nil, errors.Wrapf(ErrTimeout, "[resizing::Fetch]: request to %v: %v", url, err)
+		}
+
+		return nil, errors.Wrapf(err, "[resizing::Fetch]: failed to make request to %v", url)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, "", errors.Errorf("[resizing::GetResizedImage]: received status code %d for %s", resp.StatusCode, url)
+		return nil, errors.Wrap(&StatusError{StatusCode: resp.StatusCode, URL: url}, "[resizing::Fetch]")
+	}
+
+	var body io.Reader = resp.Body
+
+	if r.maxBodySize > 0 {
+		if resp.ContentLength > r.maxBodySize {
+			return nil, errors.Wrapf(ErrBodyTooLarge, "[resizing::Fetch]: content length %d of %s is more than %d bytes",
+				resp.ContentLength, url, r.maxBodySize)
+		}
+
+		body = io.LimitReader(resp.Body, r.maxBodySize+1)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		if isTimeout(err) {
+			return nil, errors.Wrapf(ErrTimeout, "[resizing::Fetch]: reading response body of %v: %v", url, err)
+		}
+
+		return nil, errors.Wrap(err, "[resizing::Fetch]: failed to read response body")
+	}
+
+	if r.maxBodySize > 0 && int64(len(data)) > r.maxBodySize {
+		return nil, errors.Wrapf(ErrBodyTooLarge, "[resizing::Fetch]: body of %s is more than %d bytes", url, r.maxBodySize)
+	}
+
+	return data, nil
+}
+
+// Decode decodes raw image data and extracts the metadata of the source. The dimensions declared
+// in the image header are checked against the pixel limit first, so a small file declaring huge
+// dimensions is rejected without allocating memory for its pixels.
+func (r *Resizer) Decode(data []byte) (*Image, error) {
+	if err := r.checkDimensions(data); err != nil {
+		return nil, err
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(decodeError(err), "[resizing::Decode]")
+	}
+
+	return &Image{
+		Image:  img,
+		Format: format,
+		Raw:    data,
+	}, nil
+}
+
+// DecodeConfig decodes the dimensions and the color model of an image without decoding its pixels.
+func DecodeConfig(data []byte) (image.Config, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return image.Config{}, "", errors.Wrap(decodeError(err), "[resizing::DecodeConfig]")
+	}
+
+	return cfg, format, nil
+}
+
+// decodeError converts an error of the image package to ErrUnsupportedFormat or ErrDecode.
+func decodeError(err error) error {
+	if errors.Is(err, image.ErrFormat) {
+		return ErrUnsupportedFormat
+	}
+
+	return errors.Wrapf(ErrDecode, "%v", err)
+}
+
+// isTimeout reports whether the error is caused by a timeout of the request or of the connection.
+func isTimeout(err error) bool {
+	var netErr net.Error
+
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// checkDimensions reads the image header and returns ErrImageTooLarge if the image has more pixels than allowed.
+func (r *Resizer) checkDimensions(data []byte) error {
+	if r.maxPixels == 0 {
+		return nil
+	}
+
+	cfg, _, err := DecodeConfig(data)
+	if err != nil {
+		return errors.Wrap(err, "[resizing::Decode]")
+	}
+
+	if pixels := int64(cfg.Width) * int64(cfg.Height); pixels > r.maxPixels {
+		return errors.Wrapf(ErrImageTooLarge, "[resizing::Decode]: %dx%d is more than %d pixels",
+			cfg.Width, cfg.Height, r.maxPixels)
+	}
+
+	return nil
+}
+
+type iccContextKey struct{}
+
+// NewICCContext returns a copy of the context requesting the ICC profiles of the images fetched with it.
+// Profiles are only needed to embed them into the output, so they aren't extracted otherwise.
+func NewICCContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, iccContextKey{}, true)
+}
+
+// FetchImage downloads an image from URL and decodes it.
+func (r *Resizer) FetchImage(ctx context.Context, url string, header http.Header) (*Image, error) {
+	data, err := r.Fetch(ctx, url, header)
+	if err != nil {
+		return nil, err
 	}
 
-	img, format, err := image.Decode(resp.Body)
+	img, err := r.Decode(data)
 	if err != nil {
-		return nil, "", errors.Wrap(err, "[resizing::GetResizedImage]: failed to decode image from response")
+		return nil, errors.Wrap(err, "[resizing::FetchImage]: failed to decode image from response")
 	}
 
-	resized := resize.Resize(width, height, img, resize.Lanczos3)
+	if keep, _ := ctx.Value(iccContextKey{}).(bool); keep {
+		img.ICC = metadata.ExtractICC(data, img.Format)
+	}
+
+	return img, nil
+}
+
+// Transform converts the i-th image of FetchImages as soon as it's decoded.
+type Transform func(i int, img *Image) *Image
+
+// FetchImages downloads and decodes several images using a bounded pool of workers.
+// Images are returned in the order of URLs. The first failure cancels the remaining downloads.
+// Every image is converted by the transform, if it's set, before the next one is fetched by the worker,
+// so only the converted images are kept in memory rather than the decoded sources of all of them.
+func (r *Resizer) FetchImages(ctx context.Context, urls []string, header http.Header, transform Transform) ([]*Image, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		images   = make([]*Image, len(urls))
+		jobs     = make(chan int)
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	for w := 0; w < min(r.concurrency, len(urls)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				img, err := r.FetchImage(ctx, urls[i], header)
+				if err != nil {
+					once.Do(func() {
+						firstErr = errors.Wrapf(err, "[resizing::FetchImages]: image #%d", i)
+						cancel()
+					})
+					continue
+				}
+
+				if transform != nil {
+					img = transform(i, img)
+				}
+				images[i] = img
+			}
+		}()
+	}
+
+	for i := range urls {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "[resizing::FetchImages]")
+	}
+
+	return images, nil
+}
+
+// CheckSize checks the requested size against the output limits before anything is fetched.
+// Dimensions depending on the source are checked by GetResizedImage once the source is known.
+func (r *Resizer) CheckSize(ctx context.Context, size Size) error {
+	return errors.Wrap(r.limitsFor(ctx).CheckSize(size), "[resizing::CheckSize]")
+}
+
+// CheckOutput checks the dimensions of a resized image against the output limits. It allows applying
+// the limits of the request to the images that are served from the cache.
+func (r *Resizer) CheckOutput(ctx context.Context, size image.Point) error {
+	return errors.Wrap(r.limitsFor(ctx).Check(uint(size.X), uint(size.Y)), "[resizing::CheckOutput]") //nolint:gosec
+}
+
+// CheckResize checks the dimensions the source is resized to by Resize against the output limits.
+// Zero dimensions are derived from the source the way Resize does.
+func (r *Resizer) CheckResize(ctx context.Context, width, height uint, source image.Point) error {
+	return errors.Wrap(r.limitsFor(ctx).Check(output(width, height, source)), "[resizing::CheckResize]")
+}
+
+// limitsFor returns the output limits of the resizer restricted by the limits of the request context.
+func (r *Resizer) limitsFor(ctx context.Context) Limits {
+	return r.limits.Restrict(limitsFromContext(ctx))
+}
+
+// GetResizedImage fetches an image from URL and resizes it to the specified size.
+// It returns the resized image as well as the format and the ICC profile of the original image.
+func (r *Resizer) GetResizedImage(ctx context.Context, url string, size Size, header http.Header) (*Image, error) {
+	img, err := r.FetchImage(ctx, url, header)
+	if err != nil {
+		return nil, errors.Wrap(err, "[resizing::GetResizedImage]")
+	}
+
+	width, height := size.Resolve(img.Image.Bounds().Size())
+
+	if err = r.CheckResize(ctx, width, height, img.Image.Bounds().Size()); err != nil {
+		return nil, errors.Wrap(err, "[resizing::GetResizedImage]")
+	}
+
+	return Resize(img, width, height), nil
+}
+
+// Resize returns a copy of the image resized to the specified dimensions. If one of the dimensions is 0,
+// it's calculated to preserve the aspect ratio. Source metadata is carried over to the copy,
+// the raw source only if the dimensions haven't changed.
+func Resize(img *Image, width, height uint) *Image {
+	resized := *img
+	resized.Image = resize.Resize(width, height, img.Image, resize.Lanczos3)
+
+	if resized.Image.Bounds().Size() != img.Image.Bounds().Size() {
+		resized.Raw = nil
+	}
 
-	return resized, format, nil
+	return &resized
 }
@@ -0,0 +1,258 @@
+package resizing
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/devgomax/image-previewer/internal/pkg/headerpolicy"
+	"github.com/devgomax/image-previewer/internal/pkg/hostfilter"
+	"github.com/devgomax/image-previewer/internal/pkg/metadata"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+
+	return buf.Bytes()
+}
+
+// pngHeader returns a PNG consisting only of the signature and an IHDR chunk declaring the dimensions.
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	ihdr[12] = 8 // bit depth, color type 0 (grayscale)
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, 13)
+	data = append(data, ihdr...)
+
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestDecodeMaxPixels(t *testing.T) {
+	data := encodePNG(t, 100, 50)
+
+	t.Run("no limit", func(t *testing.T) {
+		img, err := NewResizer().Decode(data)
+		require.NoError(t, err)
+		require.Equal(t, image.Pt(100, 50), img.Image.Bounds().Size())
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		img, err := NewResizer(WithMaxPixels(5000)).Decode(data)
+		require.NoError(t, err)
+		require.Equal(t, "png", img.Format)
+	})
+
+	t.Run("above limit", func(t *testing.T) {
+		_, err := NewResizer(WithMaxPixels(4999)).Decode(data)
+		require.ErrorIs(t, err, ErrImageTooLarge)
+	})
+
+	t.Run("bomb header", func(t *testing.T) {
+		_, err := NewResizer(WithMaxPixels(50_000_000)).Decode(pngHeader(50000, 50000))
+		require.ErrorIs(t, err, ErrImageTooLarge)
+	})
+
+	t.Run("not an image", func(t *testing.T) {
+		_, err := NewResizer(WithMaxPixels(5000)).Decode([]byte("not an image"))
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrImageTooLarge)
+	})
+}
+
+func TestFetchMaxBodySize(t *testing.T) {
+	body := bytes.Repeat([]byte{0xAB}, 1000)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// Flushing before writing the body forces chunked encoding without Content-Length.
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	t.Run("within limit", func(t *testing.T) {
+		data, err := NewResizer(WithMaxBodySize(1000)).Fetch(context.Background(), srv.URL+"/chunked", nil)
+		require.NoError(t, err)
+		require.Equal(t, body, data)
+	})
+
+	t.Run("content length above limit", func(t *testing.T) {
+		_, err := NewResizer(WithMaxBodySize(999)).Fetch(context.Background(), srv.URL, nil)
+		require.ErrorIs(t, err, ErrBodyTooLarge)
+	})
+
+	t.Run("streamed body above limit", func(t *testing.T) {
+		_, err := NewResizer(WithMaxBodySize(999)).Fetch(context.Background(), srv.URL+"/chunked", nil)
+		require.ErrorIs(t, err, ErrBodyTooLarge)
+	})
+}
+
+func TestFetchHostFilter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target := r.URL.Query().Get("to"); target != "" {
+			http.Redirect(w, r, target, http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("image"))
+	}))
+	defer srv.Close()
+
+	allowed, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	filter, err := hostfilter.NewFilter([]string{allowed.Host}, nil)
+	require.NoError(t, err)
+
+	r := NewResizer(WithHostFilter(filter))
+
+	data, err := r.Fetch(context.Background(), srv.URL+"/?to=/image.png", nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte("image"), data)
+
+	_, err = r.Fetch(context.Background(), "http://denied.test/image.png", nil)
+	require.ErrorIs(t, err, hostfilter.ErrNotAllowed)
+
+	_, err = r.Fetch(context.Background(), srv.URL+"/?to=http://denied.test/image.png", nil)
+	require.ErrorIs(t, err, hostfilter.ErrNotAllowed)
+
+	t.Run("request filter", func(t *testing.T) {
+		restricted, err := hostfilter.NewFilter([]string{"*.example.com"}, nil)
+		require.NoError(t, err)
+
+		ctx := hostfilter.NewContext(context.Background(), restricted)
+
+		_, err = NewResizer().Fetch(ctx, srv.URL, nil)
+		require.ErrorIs(t, err, hostfilter.ErrNotAllowed)
+
+		_, err = NewResizer().Fetch(context.Background(), srv.URL, nil)
+		require.NoError(t, err)
+	})
+}
+
+func TestFetchStatusErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		err    error
+	}{
+		{status: http.StatusNotFound, err: ErrNotFound},
+		{status: http.StatusGone, err: ErrNotFound},
+		{status: http.StatusUnauthorized, err: ErrForbidden},
+		{status: http.StatusForbidden, err: ErrForbidden},
+		{status: http.StatusGatewayTimeout, err: ErrTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			srv, _ := flakyServer(t, tt.status)
+
+			_, err := NewResizer().Fetch(context.Background(), srv.URL, nil)
+			require.ErrorIs(t, err, tt.err)
+
+			var statusErr *StatusError
+			require.ErrorAs(t, err, &statusErr)
+			require.Equal(t, tt.status, statusErr.StatusCode)
+		})
+	}
+
+	t.Run("other statuses", func(t *testing.T) {
+		srv, _ := flakyServer(t, http.StatusInternalServerError)
+
+		_, err := NewResizer().Fetch(context.Background(), srv.URL, nil)
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrNotFound)
+		require.NotErrorIs(t, err, ErrForbidden)
+		require.NotErrorIs(t, err, ErrTimeout)
+	})
+}
+
+func TestDecodeErrors(t *testing.T) {
+	r := NewResizer()
+
+	_, err := r.Decode([]byte("gopher"))
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	_, _, err = DecodeConfig([]byte("gopher"))
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	truncated := encodePNG(t, 10, 10)
+	truncated = truncated[:len(truncated)-20]
+
+	_, err = r.Decode(truncated)
+	require.ErrorIs(t, err, ErrDecode)
+	require.NotErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestFetchRedirectHeaders(t *testing.T) {
+	received := make(chan http.Header, 1)
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+		_, _ = w.Write([]byte("image"))
+	}))
+	defer target.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer origin.Close()
+
+	originURL, err := url.Parse(origin.URL)
+	require.NoError(t, err)
+
+	policy := headerpolicy.NewPolicy(headerpolicy.Rules{Allow: []string{"Accept"}})
+	require.NoError(t, policy.Override(originURL.Host, headerpolicy.Rules{Set: map[string]string{"X-Api-Key": "secret"}}))
+
+	data, err := NewResizer(WithHeaderPolicy(policy)).Fetch(context.Background(), origin.URL, http.Header{
+		"Accept":      {"image/png"},
+		"X-Forwarded": {"1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []byte("image"), data)
+
+	header := <-received
+	require.Empty(t, header.Get("X-Api-Key"))
+	require.Empty(t, header.Get("X-Forwarded"))
+	require.Equal(t, "image/png", header.Get("Accept"))
+}
+
+func TestFetchImageICC(t *testing.T) {
+	icc := bytes.Repeat([]byte("profile"), 100)
+
+	data, err := metadata.EmbedICC(encodePNG(t, 10, 10), "png", icc)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	r := NewResizer()
+
+	img, err := r.FetchImage(context.Background(), srv.URL, nil)
+	require.NoError(t, err)
+	require.Nil(t, img.ICC)
+
+	img, err = r.FetchImage(NewICCContext(context.Background()), srv.URL, nil)
+	require.NoError(t, err)
+	require.Equal(t, icc, img.ICC)
+}
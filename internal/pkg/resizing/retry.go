@@ -0,0 +1,150 @@
+package resizing
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/devgomax/image-previewer/internal/pkg/breaker"
+	"github.com/devgomax/image-previewer/internal/pkg/hostfilter"
+	"github.com/devgomax/image-previewer/internal/pkg/netguard"
+	"github.com/pkg/errors"
+)
+
+// retryPolicy describes how failed downloads are retried. Zero value makes a single attempt without a timeout.
+type retryPolicy struct {
+	retries        int
+	baseDelay      time.Duration
+	maxDelay       time.Duration
+	attemptTimeout time.Duration
+}
+
+// WithRetries makes the resizer retry failed downloads up to n times. Only the failures that are safe
+// and worth retrying are retried: connection errors, timeouts of an attempt and 502, 503 and 504 responses.
+// Delays between attempts grow exponentially from baseDelay up to maxDelay and are jittered.
+func WithRetries(n int, baseDelay, maxDelay time.Duration) Option {
+	return func(r *Resizer) {
+		if n > 0 {
+			r.retry.retries = n
+			r.retry.baseDelay = baseDelay
+			r.retry.maxDelay = max(maxDelay, baseDelay)
+		}
+	}
+}
+
+// WithAttemptTimeout limits the duration of a single download attempt, so a hanging upstream
+// leaves time for retries. Zero means no limit apart from the one of the request context.
+func WithAttemptTimeout(d time.Duration) Option {
+	return func(r *Resizer) {
+		r.retry.attemptTimeout = d
+	}
+}
+
+// WithCircuitBreaker makes the resizer fail fast for the upstream hosts the breaker has opened the circuit for.
+// Failures worth retrying are recorded as failures of the host, any response from it is recorded as a success.
+func WithCircuitBreaker(b *breaker.Breaker) Option {
+	return func(r *Resizer) {
+		r.breaker = b
+	}
+}
+
+// fetchWithRetries makes the request retrying the failures as configured.
+func (r *Resizer) fetchWithRetries(req *http.Request) ([]byte, error) {
+	ctx := req.Context()
+	host := req.URL.Host
+
+	for attempt := 0; ; attempt++ {
+		if r.breaker != nil {
+			if err := r.breaker.Allow(host); err != nil {
+				return nil, errors.Wrap(err, "[resizing::Fetch]")
+			}
+		}
+
+		data, err := r.fetchAttempt(req)
+		failed := err != nil && retryable(ctx, err)
+
+		// Requests abandoned by the client say nothing about the health of the host.
+		if r.breaker != nil && ctx.Err() == nil {
+			if failed {
+				r.breaker.Failure(host)
+			} else {
+				r.breaker.Success(host)
+			}
+		}
+
+		if !failed || attempt >= r.retry.retries {
+			return data, err
+		}
+
+		select {
+		case <-time.After(r.retry.backoff(attempt)):
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "[resizing::Fetch]: retry aborted")
+		}
+	}
+}
+
+// fetchAttempt makes a single attempt of the request limited by the attempt timeout.
+func (r *Resizer) fetchAttempt(req *http.Request) ([]byte, error) {
+	if r.retry.attemptTimeout <= 0 {
+		return r.fetch(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), r.retry.attemptTimeout)
+	defer cancel()
+
+	return r.fetch(req.Clone(ctx))
+}
+
+// backoff returns the delay before the retry following the attempt: the exponentially growing delay
+// with a random half of it subtracted, so retries of concurrent requests don't come in waves.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.maxDelay
+	if attempt < 32 && p.baseDelay<<attempt < p.maxDelay {
+		d = p.baseDelay << attempt
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + rand.N(d/2+1) //nolint:gosec
+}
+
+// retryable reports whether the failure of a request made with the context is worth retrying.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if errors.Is(err, netguard.ErrBlocked) || errors.Is(err, hostfilter.ErrNotAllowed) {
+		return false
+	}
+
+	// The request context is alive, so the deadline is the one of the attempt.
+	if errors.Is(err, ErrTimeout) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+
+	var opErr *net.OpError
+
+	return errors.As(err, &opErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
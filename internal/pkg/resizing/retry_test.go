@@ -0,0 +1,130 @@
+package resizing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devgomax/image-previewer/internal/pkg/breaker"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyServer responds with the statuses in order and with 200 OK afterwards.
+func flakyServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var hits atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if i := int(hits.Add(1)) - 1; i < len(statuses) {
+			w.WriteHeader(statuses[i])
+			return
+		}
+		_, _ = w.Write([]byte("image"))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &hits
+}
+
+func TestFetchRetries(t *testing.T) {
+	t.Run("gateway errors are retried", func(t *testing.T) {
+		srv, hits := flakyServer(t, http.StatusServiceUnavailable, http.StatusBadGateway)
+
+		data, err := NewResizer(WithRetries(2, time.Millisecond, time.Millisecond)).Fetch(context.Background(), srv.URL, nil)
+		require.NoError(t, err)
+		require.Equal(t, []byte("image"), data)
+		require.Equal(t, int32(3), hits.Load())
+	})
+
+	t.Run("retries are limited", func(t *testing.T) {
+		srv, hits := flakyServer(t, http.StatusGatewayTimeout, http.StatusGatewayTimeout, http.StatusGatewayTimeout)
+
+		_, err := NewResizer(WithRetries(1, time.Millisecond, time.Millisecond)).Fetch(context.Background(), srv.URL, nil)
+
+		var statusErr *StatusError
+		require.ErrorAs(t, err, &statusErr)
+		require.Equal(t, http.StatusGatewayTimeout, statusErr.StatusCode)
+		require.Equal(t, int32(2), hits.Load())
+	})
+
+	t.Run("other statuses are not retried", func(t *testing.T) {
+		srv, hits := flakyServer(t, http.StatusNotFound)
+
+		_, err := NewResizer(WithRetries(2, time.Millisecond, time.Millisecond)).Fetch(context.Background(), srv.URL, nil)
+		require.Error(t, err)
+		require.Equal(t, int32(1), hits.Load())
+	})
+
+	t.Run("connection errors are retried", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+
+		start := time.Now()
+		_, err := NewResizer(WithRetries(2, 20*time.Millisecond, time.Second)).Fetch(context.Background(), srv.URL, nil)
+		require.Error(t, err)
+		// Delays of 20ms and 40ms, each at least halved by the jitter.
+		require.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	})
+
+	t.Run("attempt timeout", func(t *testing.T) {
+		var hits atomic.Int32
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hits.Add(1) == 1 {
+				<-r.Context().Done()
+				return
+			}
+			_, _ = w.Write([]byte("image"))
+		}))
+		defer srv.Close()
+
+		r := NewResizer(WithRetries(1, time.Millisecond, time.Millisecond), WithAttemptTimeout(50*time.Millisecond))
+
+		data, err := r.Fetch(context.Background(), srv.URL, nil)
+		require.NoError(t, err)
+		require.Equal(t, []byte("image"), data)
+
+		hits.Store(0)
+
+		_, err = NewResizer(WithAttemptTimeout(50*time.Millisecond)).Fetch(context.Background(), srv.URL, nil)
+		require.ErrorIs(t, err, ErrTimeout)
+	})
+}
+
+func TestFetchCircuitBreaker(t *testing.T) {
+	srv, hits := flakyServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+
+	r := NewResizer(WithCircuitBreaker(breaker.NewBreaker(2, time.Hour)))
+
+	for i := 0; i < 2; i++ {
+		_, err := r.Fetch(context.Background(), srv.URL, nil)
+		require.Error(t, err)
+		require.NotErrorIs(t, err, breaker.ErrOpen)
+	}
+
+	_, err := r.Fetch(context.Background(), srv.URL, nil)
+	require.ErrorIs(t, err, breaker.ErrOpen)
+	require.Equal(t, int32(2), hits.Load())
+}
+
+func TestBackoff(t *testing.T) {
+	p := retryPolicy{baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			d := p.backoff(attempt)
+			require.GreaterOrEqual(t, d, want/2)
+			require.LessOrEqual(t, d, want)
+		}
+	}
+
+	// Large attempts don't overflow the shift.
+	d := p.backoff(100)
+	require.GreaterOrEqual(t, d, p.maxDelay/2)
+	require.LessOrEqual(t, d, p.maxDelay)
+}
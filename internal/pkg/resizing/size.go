@@ -0,0 +1,173 @@
+package resizing
+
+import (
+	"context"
+	"image"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// ErrSizeTooLarge is returned when the requested size exceeds the output limits.
+var ErrSizeTooLarge = errors.New("requested size exceeds the limit")
+
+// Dimension is a requested image dimension, either in pixels or in percent of the source dimension.
+// Zero value means the dimension is derived from the other one.
+type Dimension struct {
+	Value   uint
+	Percent bool
+}
+
+// String formats the dimension the way it's passed in the URL: "300" or "50p".
+func (d Dimension) String() string {
+	s := strconv.FormatUint(uint64(d.Value), 10)
+	if d.Percent {
+		s += "p"
+	}
+
+	return s
+}
+
+// resolve returns the dimension in pixels for the source dimension. Non-zero percentages never resolve to 0.
+func (d Dimension) resolve(source int) uint {
+	if !d.Percent || d.Value == 0 {
+		return d.Value
+	}
+
+	return uint(max(math.Round(float64(source)*float64(d.Value)/100), 1))
+}
+
+// Size is a requested image size.
+type Size struct {
+	Width  Dimension
+	Height Dimension
+	// AspectRatio is the width to height ratio used to derive the missing dimension. Zero means the ratio of the source.
+	AspectRatio float64
+}
+
+// String formats the size as "width:height" with the aspect ratio appended if it's set.
+func (s Size) String() string {
+	str := s.Width.String() + ":" + s.Height.String()
+	if s.AspectRatio > 0 {
+		str += ":" + strconv.FormatFloat(s.AspectRatio, 'g', -1, 64)
+	}
+
+	return str
+}
+
+// Resolve returns the size in pixels for an image of the source size. A zero dimension in the result
+// means it should be derived from the ratio of the source.
+func (s Size) Resolve(source image.Point) (uint, uint) {
+	width, height := s.Width.resolve(source.X), s.Height.resolve(source.Y)
+
+	if s.AspectRatio > 0 {
+		switch {
+		case width == 0 && height > 0:
+			width = uint(max(math.Round(float64(height)*s.AspectRatio), 1))
+		case height == 0 && width > 0:
+			height = uint(max(math.Round(float64(width)/s.AspectRatio), 1))
+		}
+	}
+
+	return width, height
+}
+
+// known returns the pixel dimensions that are known without the source: pixel dimensions
+// and the ones derived from them by the aspect ratio. Unknown dimensions are returned as 0.
+func (s Size) known() (uint, uint) {
+	var width, height uint
+	if !s.Width.Percent {
+		width = s.Width.Value
+	}
+	if !s.Height.Percent {
+		height = s.Height.Value
+	}
+
+	if s.AspectRatio > 0 && s.Width.Value == 0 && height > 0 {
+		width = uint(max(math.Round(float64(height)*s.AspectRatio), 1))
+	}
+	if s.AspectRatio > 0 && s.Height.Value == 0 && width > 0 {
+		height = uint(max(math.Round(float64(width)/s.AspectRatio), 1))
+	}
+
+	return width, height
+}
+
+// Limits restricts the size of output images. Zero fields mean no limit.
+type Limits struct {
+	Width  uint
+	Height uint
+	Pixels uint64
+}
+
+// Check returns ErrSizeTooLarge if the dimensions exceed the limits. Zero dimensions are unknown
+// and aren't checked.
+func (l Limits) Check(width, height uint) error {
+	switch {
+	case l.Width > 0 && width > l.Width:
+		return errors.Wrapf(ErrSizeTooLarge, "width %d is more than %d", width, l.Width)
+	case l.Height > 0 && height > l.Height:
+		return errors.Wrapf(ErrSizeTooLarge, "height %d is more than %d", height, l.Height)
+	case l.Pixels > 0 && uint64(width)*uint64(height) > l.Pixels:
+		return errors.Wrapf(ErrSizeTooLarge, "%dx%d is more than %d pixels", width, height, l.Pixels)
+	default:
+		return nil
+	}
+}
+
+// Restrict returns the limits that are the strictest of the two.
+func (l Limits) Restrict(o Limits) Limits {
+	return Limits{
+		Width:  minLimit(l.Width, o.Width),
+		Height: minLimit(l.Height, o.Height),
+		Pixels: minLimit(l.Pixels, o.Pixels),
+	}
+}
+
+// minLimit returns the smaller of two limits, where zero means no limit.
+func minLimit[T uint | uint64](a, b T) T {
+	switch {
+	case a == 0:
+		return b
+	case b == 0:
+		return a
+	default:
+		return min(a, b)
+	}
+}
+
+type limitsContextKey struct{}
+
+// NewLimitsContext returns a copy of the context carrying the limits. They restrict the size of images
+// resized on behalf of a single request in addition to the limits of the Resizer.
+func NewLimitsContext(ctx context.Context, l Limits) context.Context {
+	return context.WithValue(ctx, limitsContextKey{}, l)
+}
+
+// limitsFromContext returns the limits carried by the context, zero limits if there are none.
+func limitsFromContext(ctx context.Context) Limits {
+	l, _ := ctx.Value(limitsContextKey{}).(Limits)
+	return l
+}
+
+// CheckSize checks the dimensions of the size that are known without the source against the limits.
+// It allows rejecting unacceptable sizes before fetching the source.
+func (l Limits) CheckSize(s Size) error {
+	return l.Check(s.known())
+}
+
+// output returns the dimensions of the image the source is resized to, deriving zero dimensions
+// from the ratio of the source the way Resize does.
+func output(width, height uint, source image.Point) (uint, uint) {
+	switch {
+	case width == 0 && height == 0:
+		return uint(source.X), uint(source.Y) //nolint:gosec
+	case width == 0 && source.Y > 0:
+		return uint(max(math.Round(float64(height)*float64(source.X)/float64(source.Y)), 1)), height
+	case height == 0 && source.X > 0:
+		return width, uint(max(math.Round(float64(width)*float64(source.Y)/float64(source.X)), 1))
+	default:
+		return width, height
+	}
+}
@@ -0,0 +1,134 @@
+package resizing
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizeResolve(t *testing.T) {
+	source := image.Pt(2000, 1000)
+
+	tests := []struct {
+		name   string
+		size   Size
+		width  uint
+		height uint
+	}{
+		{
+			name:   "pixels",
+			size:   Size{Width: Dimension{Value: 300}, Height: Dimension{Value: 200}},
+			width:  300,
+			height: 200,
+		},
+		{
+			name:   "percent",
+			size:   Size{Width: Dimension{Value: 50, Percent: true}, Height: Dimension{Value: 10, Percent: true}},
+			width:  1000,
+			height: 100,
+		},
+		{
+			name:   "percent with derived height",
+			size:   Size{Width: Dimension{Value: 50, Percent: true}},
+			width:  1000,
+			height: 0,
+		},
+		{
+			name:   "tiny percent is at least one pixel",
+			size:   Size{Width: Dimension{Value: 1, Percent: true}, Height: Dimension{Value: 1, Percent: true}},
+			width:  20,
+			height: 10,
+		},
+		{
+			name:   "aspect ratio with width",
+			size:   Size{Width: Dimension{Value: 1600}, AspectRatio: 16.0 / 9},
+			width:  1600,
+			height: 900,
+		},
+		{
+			name:   "aspect ratio with height",
+			size:   Size{Height: Dimension{Value: 50, Percent: true}, AspectRatio: 1},
+			width:  500,
+			height: 500,
+		},
+		{
+			name:   "aspect ratio without dimensions",
+			size:   Size{AspectRatio: 2},
+			width:  0,
+			height: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height := tt.size.Resolve(source)
+			require.Equal(t, tt.width, width)
+			require.Equal(t, tt.height, height)
+		})
+	}
+}
+
+func TestSizeString(t *testing.T) {
+	require.Equal(t, "0:0", Size{}.String())
+	require.Equal(t, "50p:200", Size{Width: Dimension{Value: 50, Percent: true}, Height: Dimension{Value: 200}}.String())
+	require.Equal(t, "300:0:1.5", Size{Width: Dimension{Value: 300}, AspectRatio: 1.5}.String())
+}
+
+func TestLimits(t *testing.T) {
+	limits := Limits{Width: 1000, Height: 800, Pixels: 500_000}
+
+	t.Run("check", func(t *testing.T) {
+		require.NoError(t, Limits{}.Check(4000000000, 4000000000))
+		require.NoError(t, limits.Check(1000, 500))
+		require.NoError(t, limits.Check(0, 0))
+		require.ErrorIs(t, limits.Check(1001, 1), ErrSizeTooLarge)
+		require.ErrorIs(t, limits.Check(1, 801), ErrSizeTooLarge)
+		require.ErrorIs(t, limits.Check(1000, 501), ErrSizeTooLarge)
+	})
+
+	t.Run("check size", func(t *testing.T) {
+		require.NoError(t, limits.CheckSize(Size{Width: Dimension{Value: 1000}}))
+		require.NoError(t, limits.CheckSize(Size{Width: Dimension{Value: 400, Percent: true}, Height: Dimension{Value: 800}}))
+		require.ErrorIs(t, limits.CheckSize(Size{Width: Dimension{Value: 4000000000}}), ErrSizeTooLarge)
+		require.ErrorIs(t, limits.CheckSize(Size{Width: Dimension{Value: 1000}, AspectRatio: 1}), ErrSizeTooLarge)
+		require.ErrorIs(t, limits.CheckSize(Size{Height: Dimension{Value: 700}, AspectRatio: 2}), ErrSizeTooLarge)
+	})
+}
+
+func TestOutput(t *testing.T) {
+	source := image.Pt(2000, 1000)
+
+	width, height := output(0, 0, source)
+	require.Equal(t, []uint{2000, 1000}, []uint{width, height})
+
+	width, height = output(500, 0, source)
+	require.Equal(t, []uint{500, 250}, []uint{width, height})
+
+	width, height = output(0, 500, source)
+	require.Equal(t, []uint{1000, 500}, []uint{width, height})
+
+	width, height = output(300, 300, source)
+	require.Equal(t, []uint{300, 300}, []uint{width, height})
+}
+
+func TestLimitsRestrict(t *testing.T) {
+	l := Limits{Width: 1000, Pixels: 500_000}
+
+	require.Equal(t, l, l.Restrict(Limits{}))
+	require.Equal(t, l, Limits{}.Restrict(l))
+	require.Equal(t, Limits{Width: 400, Height: 300, Pixels: 500_000}, l.Restrict(Limits{Width: 400, Height: 300}))
+}
+
+func TestLimitsContext(t *testing.T) {
+	require.Equal(t, Limits{}, limitsFromContext(context.Background()))
+
+	ctx := NewLimitsContext(context.Background(), Limits{Width: 400})
+	require.Equal(t, Limits{Width: 400}, limitsFromContext(ctx))
+
+	r := NewResizer(WithOutputLimits(Limits{Width: 1000, Height: 1000}))
+	require.NoError(t, r.CheckSize(context.Background(), Size{Width: Dimension{Value: 1000}}))
+	require.ErrorIs(t, r.CheckSize(ctx, Size{Width: Dimension{Value: 401}}), ErrSizeTooLarge)
+	require.ErrorIs(t, r.CheckSize(ctx, Size{Height: Dimension{Value: 1001}}), ErrSizeTooLarge)
+}
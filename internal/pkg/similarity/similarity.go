@@ -0,0 +1,178 @@
+package similarity
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// windowSize is the side of the square windows SSIM is computed on.
+	windowSize = 8
+
+	// diffThreshold is the minimum per-channel difference highlighted by Diff.
+	diffThreshold = 16
+)
+
+var (
+	// ErrSizeMismatch is returned when the compared images have different dimensions.
+	ErrSizeMismatch = errors.New("images have different sizes")
+
+	// highlight is the color of changed pixels in the visual diff.
+	highlight = color.NRGBA{R: 0xff, A: 0xff}
+
+	c1 = math.Pow(0.01*255, 2)
+	c2 = math.Pow(0.03*255, 2)
+)
+
+// SSIM computes the mean structural similarity index of two images of the same size on their luminance.
+// The index is averaged over non-overlapping 8x8 windows, 1 means the images are identical.
+func SSIM(a, b image.Image) (float64, error) {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return 0, errors.Wrap(ErrSizeMismatch, "[similarity::SSIM]")
+	}
+
+	la, lb := luminance(a), luminance(b)
+	width, height := a.Bounds().Dx(), a.Bounds().Dy()
+
+	var (
+		total   float64
+		windows int
+	)
+
+	for y0 := 0; y0 < height; y0 += windowSize {
+		for x0 := 0; x0 < width; x0 += windowSize {
+			x1, y1 := min(x0+windowSize, width), min(y0+windowSize, height)
+			total += windowSSIM(la, lb, width, x0, y0, x1, y1)
+			windows++
+		}
+	}
+
+	if windows == 0 {
+		return 1, nil
+	}
+
+	return total / float64(windows), nil
+}
+
+func windowSSIM(la, lb []float64, stride, x0, y0, x1, y1 int) float64 {
+	n := float64((x1 - x0) * (y1 - y0))
+
+	var meanA, meanB float64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			meanA += la[y*stride+x]
+			meanB += lb[y*stride+x]
+		}
+	}
+	meanA /= n
+	meanB /= n
+
+	var varA, varB, cov float64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			da, db := la[y*stride+x]-meanA, lb[y*stride+x]-meanB
+			varA += da * da
+			varB += db * db
+			cov += da * db
+		}
+	}
+	varA /= n
+	varB /= n
+	cov /= n
+
+	return ((2*meanA*meanB + c1) * (2*cov + c2)) / ((meanA*meanA + meanB*meanB + c1) * (varA + varB + c2))
+}
+
+// PSNR computes the peak signal-to-noise ratio in decibels of two images of the same size over RGB channels.
+// It returns +Inf for identical images.
+func PSNR(a, b image.Image) (float64, error) {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return 0, errors.Wrap(ErrSizeMismatch, "[similarity::PSNR]")
+	}
+
+	var (
+		sum     float64
+		samples int
+	)
+
+	forEachPair(a, b, func(_, _ int, ca, cb color.NRGBA) {
+		for _, d := range [3]float64{
+			float64(ca.R) - float64(cb.R),
+			float64(ca.G) - float64(cb.G),
+			float64(ca.B) - float64(cb.B),
+		} {
+			sum += d * d
+		}
+		samples += 3
+	})
+
+	if sum == 0 || samples == 0 {
+		return math.Inf(1), nil
+	}
+
+	mse := sum / float64(samples)
+
+	return 10 * math.Log10(255*255/mse), nil
+}
+
+// Diff renders a visual diff of two images of the same size: unchanged pixels are shown as a faded
+// grayscale copy of the first image and changed pixels are highlighted in red.
+func Diff(a, b image.Image) (*image.NRGBA, error) {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return nil, errors.Wrap(ErrSizeMismatch, "[similarity::Diff]")
+	}
+
+	out := image.NewNRGBA(image.Rect(0, 0, a.Bounds().Dx(), a.Bounds().Dy()))
+
+	forEachPair(a, b, func(x, y int, ca, cb color.NRGBA) {
+		if absDiff(ca.R, cb.R) > diffThreshold || absDiff(ca.G, cb.G) > diffThreshold ||
+			absDiff(ca.B, cb.B) > diffThreshold || absDiff(ca.A, cb.A) > diffThreshold {
+			out.SetNRGBA(x, y, highlight)
+			return
+		}
+
+		gray := color.GrayModel.Convert(ca).(color.Gray).Y
+		faded := 0xff - (0xff-gray)/3
+		out.SetNRGBA(x, y, color.NRGBA{R: faded, G: faded, B: faded, A: 0xff})
+	})
+
+	return out, nil
+}
+
+// forEachPair calls fn for every pair of pixels at the same position of two images of the same size.
+// Coordinates are relative to the image bounds.
+func forEachPair(a, b image.Image, fn func(x, y int, ca, cb color.NRGBA)) {
+	ba, bb := a.Bounds(), b.Bounds()
+
+	for y := 0; y < ba.Dy(); y++ {
+		for x := 0; x < ba.Dx(); x++ {
+			ca := color.NRGBAModel.Convert(a.At(ba.Min.X+x, ba.Min.Y+y)).(color.NRGBA)
+			cb := color.NRGBAModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y)).(color.NRGBA)
+			fn(x, y, ca, cb)
+		}
+	}
+}
+
+// luminance returns the luma of every pixel of an image row by row.
+func luminance(img image.Image) []float64 {
+	b := img.Bounds()
+
+	pixels := make([]float64, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			pixels = append(pixels, float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y))
+		}
+	}
+
+	return pixels
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
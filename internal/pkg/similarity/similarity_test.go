@@ -0,0 +1,83 @@
+package similarity
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func checkerboard(size int, shift uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			v := uint8(40)
+			if (x/4+y/4)%2 == 0 {
+				v = 200
+			}
+			img.Set(x, y, color.RGBA{R: v + shift, G: v + shift, B: v + shift, A: 255})
+		}
+	}
+	return img
+}
+
+func TestSimilarity(t *testing.T) {
+	original := checkerboard(32, 0)
+
+	t.Run("identical images", func(t *testing.T) {
+		ssim, err := SSIM(original, checkerboard(32, 0))
+		require.NoError(t, err)
+		require.InDelta(t, 1, ssim, 1e-9)
+
+		psnr, err := PSNR(original, checkerboard(32, 0))
+		require.NoError(t, err)
+		require.True(t, math.IsInf(psnr, 1))
+	})
+
+	t.Run("slightly changed image", func(t *testing.T) {
+		changed := checkerboard(32, 10)
+
+		ssim, err := SSIM(original, changed)
+		require.NoError(t, err)
+		require.Less(t, ssim, 1.0)
+		require.Greater(t, ssim, 0.9)
+
+		psnr, err := PSNR(original, changed)
+		require.NoError(t, err)
+		require.InDelta(t, 20*math.Log10(255.0/10), psnr, 1e-9)
+	})
+
+	t.Run("different images", func(t *testing.T) {
+		inverted := checkerboard(32, 0)
+		draw.Draw(inverted, inverted.Bounds(), original, image.Pt(4, 0), draw.Src)
+
+		ssim, err := SSIM(original, inverted)
+		require.NoError(t, err)
+		require.Less(t, ssim, 0.5)
+	})
+
+	t.Run("size mismatch", func(t *testing.T) {
+		_, err := SSIM(original, checkerboard(16, 0))
+		require.ErrorIs(t, err, ErrSizeMismatch)
+
+		_, err = PSNR(original, checkerboard(16, 0))
+		require.ErrorIs(t, err, ErrSizeMismatch)
+
+		_, err = Diff(original, checkerboard(16, 0))
+		require.ErrorIs(t, err, ErrSizeMismatch)
+	})
+}
+
+func TestDiff(t *testing.T) {
+	a := checkerboard(16, 0)
+	b := checkerboard(16, 0)
+	b.Set(3, 5, color.RGBA{G: 255, A: 255})
+
+	diff, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Equal(t, highlight, diff.NRGBAAt(3, 5))
+	require.NotEqual(t, highlight, diff.NRGBAAt(4, 5))
+}
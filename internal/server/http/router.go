@@ -15,6 +15,17 @@ func NewRouter(app *imagepreviewer.App, middlewares ...func(http.Handler) http.H
 	}
 
 	r.Get("/fill/{width}/{height}/*", app.PreviewImage)
+	r.Get("/convert/{format}/*", app.ConvertImage)
+	r.Get("/info/*", app.ImageInfo)
+	r.Get("/placeholder/{kind}/*", app.Placeholder)
+	r.Get("/palette/*", app.Palette)
+	r.Get("/hash/{algo}/*", app.ImageHash)
+	r.Get("/compare/{algo}", app.CompareImages)
+	r.Get("/diff", app.DiffImages)
+	r.Post("/batch", app.Batch)
+	r.Post("/collage", app.Collage)
+	r.Post("/sprite", app.Sprite)
+	r.Get("/dummy/{width}/{height}", app.DummyImage)
 
 	return r
 }
@@ -0,0 +1,287 @@
+package internalhttp
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	imagepreviewer "github.com/devgomax/image-previewer/internal/app/image_previewer"
+	"github.com/devgomax/image-previewer/internal/pkg/lru"
+	"github.com/devgomax/image-previewer/internal/pkg/resizing"
+	"github.com/devgomax/image-previewer/pkg/urlsign"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterTokenLimits(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 1000, 500))))
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer upstream.Close()
+
+	src := upstream.URL + "/image.png"
+
+	signer := urlsign.NewSigner([]byte("key"))
+	app := imagepreviewer.NewApp(lru.NewCache(10), resizing.NewResizer())
+	router := NewRouter(app, SignatureMiddleware(signer))
+
+	token, err := signer.Token(urlsign.Claims{ExpiresAt: time.Now().Add(time.Hour).Unix(), MaxWidth: 200, MaxHeight: 200})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		allowed bool
+	}{
+		{name: "fill within limits", method: http.MethodGet, target: "/fill/200/0/" + src, allowed: true},
+		{name: "fill", method: http.MethodGet, target: "/fill/300/0/" + src},
+		{name: "fill at source size", method: http.MethodGet, target: "/fill/0/0/" + src},
+		{name: "convert", method: http.MethodGet, target: "/convert/png/" + src},
+		{
+			name:    "batch within limits",
+			method:  http.MethodPost,
+			target:  "/batch",
+			body:    fmt.Sprintf(`{"url":%q,"renditions":[{"width":200}]}`, src),
+			allowed: true,
+		},
+		{
+			name:   "batch",
+			method: http.MethodPost,
+			target: "/batch",
+			body:   fmt.Sprintf(`{"url":%q,"renditions":[{"width":200},{"height":300}]}`, src),
+		},
+		{
+			name:    "sprite within limits",
+			method:  http.MethodPost,
+			target:  "/sprite",
+			body:    fmt.Sprintf(`{"images":[{"url":%q,"width":100,"height":50},{"url":%q,"width":100,"height":50}]}`, src, src),
+			allowed: true,
+		},
+		{
+			name:   "sprite image",
+			method: http.MethodPost,
+			target: "/sprite",
+			body:   fmt.Sprintf(`{"images":[{"url":%q,"width":300,"height":150}]}`, src),
+		},
+		{
+			name:   "sprite sheet",
+			method: http.MethodPost,
+			target: "/sprite",
+			body:   fmt.Sprintf(`{"images":[{"url":%q,"width":150,"height":150},{"url":%q,"width":150,"height":150}]}`, src, src),
+		},
+		{
+			name:    "collage within limits",
+			method:  http.MethodPost,
+			target:  "/collage",
+			body:    fmt.Sprintf(`{"urls":[%q],"columns":1,"cell_width":200,"cell_height":100}`, src),
+			allowed: true,
+		},
+		{
+			name:   "collage",
+			method: http.MethodPost,
+			target: "/collage",
+			body:   fmt.Sprintf(`{"urls":[%q,%q],"columns":2,"cell_width":200,"cell_height":100}`, src, src),
+		},
+		{name: "dummy within limits", method: http.MethodGet, target: "/dummy/200/100", allowed: true},
+		{name: "dummy", method: http.MethodGet, target: "/dummy/300/100", allowed: false},
+		{
+			name:    "diff within limits",
+			method:  http.MethodGet,
+			target:  "/diff?visual=true&width=200&a=" + url.QueryEscape(src) + "&b=" + url.QueryEscape(src),
+			allowed: true,
+		},
+		{
+			name:   "diff",
+			method: http.MethodGet,
+			target: "/diff?visual=true&a=" + url.QueryEscape(src) + "&b=" + url.QueryEscape(src),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if tt.allowed {
+				require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			} else {
+				require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRouterBatchLimits(t *testing.T) {
+	var hits atomic.Int32
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		_ = png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+	}))
+	defer upstream.Close()
+
+	resizer := resizing.NewResizer(resizing.WithOutputLimits(resizing.Limits{Width: 4096, Height: 4096}))
+	router := NewRouter(imagepreviewer.NewApp(lru.NewCache(10), resizer))
+
+	do := func(renditions string) int {
+		body := fmt.Sprintf(`{"url":%q,"renditions":[%s]}`, upstream.URL, renditions)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(body)))
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusBadRequest, do(`{"width":100000,"height":100000}`))
+	require.Equal(t, http.StatusBadRequest, do(`{"width":100},{"width":5000}`))
+	require.Equal(t, http.StatusBadRequest, do(strings.Repeat(`{"width":10},`, 32)+`{"width":10}`))
+	require.Equal(t, int32(0), hits.Load())
+
+	require.Equal(t, http.StatusOK, do(`{"width":100},{"height":20}`))
+}
+
+func TestRouterSpriteLimits(t *testing.T) {
+	var hits atomic.Int32
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		_ = png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+	}))
+	defer upstream.Close()
+
+	router := NewRouter(imagepreviewer.NewApp(lru.NewCache(10), resizing.NewResizer()))
+
+	do := func(body string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sprite", strings.NewReader(body)))
+
+		return rec.Code
+	}
+
+	item := func(width, height int) string {
+		return fmt.Sprintf(`{"url":%q,"width":%d,"height":%d}`, upstream.URL, width, height)
+	}
+
+	tooTall := strings.Repeat(item(8192, 8192)+",", 2) + item(8192, 8192)
+	require.Equal(t, http.StatusBadRequest, do(`{"images":[`+tooTall+`]}`))
+	require.Equal(t, http.StatusBadRequest, do(`{"images":[`+item(10, 10)+`],"padding":1000}`))
+	require.Equal(t, http.StatusBadRequest, do(`{"images":[`+item(10, 0)+`]}`))
+	require.Equal(t, int32(0), hits.Load())
+
+	require.Equal(t, http.StatusOK, do(`{"images":[`+item(10, 10)+`,`+item(20, 5)+`],"padding":2}`))
+	require.Equal(t, int32(2), hits.Load())
+}
+
+func TestRouterPlaceholderComponents(t *testing.T) {
+	var hits atomic.Int32
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		_ = png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+	}))
+	defer upstream.Close()
+
+	router := NewRouter(imagepreviewer.NewApp(lru.NewCache(10), resizing.NewResizer()))
+
+	do := func(query string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/placeholder/blurhash/"+upstream.URL+"?"+query, nil))
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusBadRequest, do("x=0"))
+	require.Equal(t, http.StatusBadRequest, do("x=10"))
+	require.Equal(t, http.StatusBadRequest, do("y=-1"))
+	require.Equal(t, int32(0), hits.Load())
+
+	require.Equal(t, http.StatusOK, do("x=9&y=1"))
+}
+
+func TestRouterDummyText(t *testing.T) {
+	router := NewRouter(imagepreviewer.NewApp(lru.NewCache(10), resizing.NewResizer()))
+
+	do := func(text string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dummy/100/100?text="+url.QueryEscape(text), nil))
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, do(strings.Repeat("я", 256)))
+	require.Equal(t, http.StatusBadRequest, do(strings.Repeat("a", 257)))
+}
+
+func TestRouterPlaceholderKindParams(t *testing.T) {
+	var hits atomic.Int32
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		_ = png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+	}))
+	defer upstream.Close()
+
+	router := NewRouter(imagepreviewer.NewApp(lru.NewCache(10), resizing.NewResizer()))
+
+	do := func(kind, query string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/placeholder/"+kind+"/"+upstream.URL+"?"+query, nil))
+
+		return rec.Code
+	}
+
+	// Params of the other kinds are ignored and share the cache entry.
+	require.Equal(t, http.StatusOK, do("svg", "size=100"))
+	require.Equal(t, http.StatusOK, do("svg", "x=100"))
+	require.Equal(t, int32(1), hits.Load())
+
+	require.Equal(t, http.StatusOK, do("lqip", "x=100"))
+	require.Equal(t, http.StatusOK, do("lqip", "x=1"))
+	require.Equal(t, int32(2), hits.Load())
+
+	require.Equal(t, http.StatusOK, do("blurhash", "size=100"))
+	require.Equal(t, http.StatusOK, do("blurhash", "size=1"))
+	require.Equal(t, int32(3), hits.Load())
+
+	require.Equal(t, http.StatusBadRequest, do("lqip", "size=100"))
+}
+
+func TestRouterDiffIdentical(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+	}))
+	defer upstream.Close()
+
+	router := NewRouter(imagepreviewer.NewApp(lru.NewCache(10), resizing.NewResizer()))
+
+	do := func(query string) *httptest.ResponseRecorder {
+		src := url.QueryEscape(upstream.URL)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/diff?a="+src+"&b="+src+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		return rec
+	}
+
+	require.Contains(t, do("").Body.String(), `"psnr":null`)
+
+	rec := do("&visual=true")
+	require.NotEmpty(t, rec.Header().Get("X-SSIM"))
+	require.NotContains(t, rec.Header(), "X-Psnr")
+}
@@ -0,0 +1,135 @@
+package internalhttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/devgomax/image-previewer/internal/pkg/hostfilter"
+	"github.com/devgomax/image-previewer/internal/pkg/resizing"
+	"github.com/devgomax/image-previewer/pkg/urlsign"
+	"github.com/rs/zerolog/log"
+)
+
+// maxSignedBodySize is the maximum size of a request body covered by the signature.
+const maxSignedBodySize = 1 << 20
+
+// SignatureMiddleware requires every request path to start with a valid signature segment, see package urlsign.
+// Requests with a missing, invalid or expired signature are rejected with 403, the segment is stripped
+// from the path of the valid ones, so they are routed as unsigned.
+// Alternatively a request may carry a token in the token query parameter or in the Authorization header,
+// then its path isn't signed and the request is restricted by the token claims instead.
+func SignatureMiddleware(signer *urlsign.Signer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token := requestToken(r); token != "" {
+				serveWithToken(w, r, next, signer, token)
+				return
+			}
+
+			sig, path, err := urlsign.Split(r.URL.EscapedPath())
+			if err != nil {
+				log.Error().Err(err).Msg("[internalhttp::SignatureMiddleware]: unsigned request")
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			signed := path
+			if r.URL.RawQuery != "" {
+				signed += "?" + r.URL.RawQuery
+			}
+
+			var body []byte
+			if r.Body != nil && r.Body != http.NoBody {
+				if body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodySize)); err != nil {
+					log.Error().Err(err).Msg("[internalhttp::SignatureMiddleware]: failed to read request body")
+					http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			if err = signer.Verify(sig, signed, body); err != nil {
+				log.Error().Err(err).Msg("[internalhttp::SignatureMiddleware]: invalid signature")
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			if err = urlsign.CheckExpiry(r.URL.Query(), time.Now()); err != nil {
+				log.Error().Err(err).Msg("[internalhttp::SignatureMiddleware]: signature expired")
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			if err = setEscapedPath(r.URL, path); err != nil {
+				log.Error().Err(err).Msg("[internalhttp::SignatureMiddleware]: failed to strip signature")
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestToken returns the token of the request passed in the token query parameter or as a bearer token.
+func requestToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return token
+}
+
+// serveWithToken verifies the token and serves the request restricted by its claims: the operation is checked
+// here, while the origins of the source images and the size of previews are restricted through the request context.
+func serveWithToken(w http.ResponseWriter, r *http.Request, next http.Handler, signer *urlsign.Signer, token string) {
+	claims, err := signer.ParseToken(token, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("[internalhttp::SignatureMiddleware]: invalid token")
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	op, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !claims.AllowsOperation(op) {
+		log.Error().Str("operation", op).Msg("[internalhttp::SignatureMiddleware]: operation is not allowed by the token")
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	ctx := resizing.NewLimitsContext(r.Context(), resizing.Limits{Width: claims.MaxWidth, Height: claims.MaxHeight})
+
+	if len(claims.Origins) > 0 {
+		origins, err := hostfilter.NewFilter(claims.Origins, nil)
+		if err != nil {
+			log.Error().Err(err).Msg("[internalhttp::SignatureMiddleware]: invalid origins in the token")
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		ctx = hostfilter.NewContext(ctx, origins)
+	}
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// setEscapedPath replaces the path of the URL, keeping the raw path only if it differs from the default escaping,
+// the same way url.Parse does.
+func setEscapedPath(u *url.URL, escaped string) error {
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		return err
+	}
+
+	u.Path, u.RawPath = path, ""
+	if u.EscapedPath() != escaped {
+		u.RawPath = escaped
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package internalhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devgomax/image-previewer/internal/pkg/hostfilter"
+	"github.com/devgomax/image-previewer/internal/pkg/resizing"
+	"github.com/devgomax/image-previewer/pkg/urlsign"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureMiddleware(t *testing.T) {
+	signer := urlsign.NewSigner([]byte("key"))
+
+	r := chi.NewRouter()
+	r.Use(SignatureMiddleware(signer))
+	r.Get("/fill/{width}/{height}/*", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, chi.URLParam(r, "width")+" "+chi.URLParam(r, "*")+" "+r.URL.RawQuery)
+	})
+	r.Post("/batch", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	})
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	path := "/fill/300/200/http://example.com/a%20b.jpg?format=png"
+
+	rec := do(http.MethodGet, signer.Sign(path), "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "300 http://example.com/a b.jpg format=png", rec.Body.String())
+
+	require.Equal(t, http.StatusForbidden, do(http.MethodGet, path, "").Code)
+	require.Equal(t, http.StatusForbidden, do(http.MethodGet, signer.Sign(path)+"&quality=1", "").Code)
+	require.Equal(t, http.StatusForbidden, do(http.MethodGet, "/"+signer.Signature(path, nil)+"/fill/301/200/x", "").Code)
+
+	body := `{"url":"http://example.com/a.jpg"}`
+	rec = do(http.MethodPost, "/"+signer.Signature("/batch", []byte(body))+"/batch", body)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, body, rec.Body.String())
+
+	require.Equal(t, http.StatusForbidden, do(http.MethodPost, "/"+signer.Signature("/batch", nil)+"/batch", body).Code)
+
+	t.Run("expiry", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, do(http.MethodGet, signer.SignWithExpiry(path, time.Now().Add(time.Minute)), "").Code)
+		require.Equal(t, http.StatusForbidden, do(http.MethodGet, signer.SignWithExpiry(path, time.Now().Add(-time.Minute)), "").Code)
+	})
+}
+
+func TestSignatureMiddlewareToken(t *testing.T) {
+	signer := urlsign.NewSigner([]byte("key"))
+	resizer := resizing.NewResizer()
+
+	r := chi.NewRouter()
+	r.Use(SignatureMiddleware(signer))
+	r.Get("/fill/{width}/{height}/*", func(w http.ResponseWriter, r *http.Request) {
+		width, _ := strconv.ParseUint(chi.URLParam(r, "width"), 10, 32)
+		if err := resizer.CheckSize(r.Context(), resizing.Size{Width: resizing.Dimension{Value: uint(width)}}); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		source, _ := url.Parse(chi.URLParam(r, "*"))
+		if f, ok := hostfilter.FromContext(r.Context()); ok && f.Check(source) != nil {
+			http.Error(w, "origin", http.StatusForbidden)
+			return
+		}
+	})
+	r.Get("/info/*", func(http.ResponseWriter, *http.Request) {})
+
+	token, err := signer.Token(urlsign.Claims{
+		ExpiresAt:  time.Now().Add(time.Hour).Unix(),
+		Origins:    []string{"*.example.com"},
+		MaxWidth:   400,
+		Operations: []string{"fill"},
+	})
+	require.NoError(t, err)
+
+	do := func(target, bearer string) int {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, do("/fill/400/0/http://img.example.com/a.jpg?token="+token, ""))
+	require.Equal(t, http.StatusOK, do("/fill/400/0/http://img.example.com/a.jpg", token))
+	require.Equal(t, http.StatusBadRequest, do("/fill/401/0/http://img.example.com/a.jpg", token))
+	require.Equal(t, http.StatusForbidden, do("/fill/400/0/http://other.com/a.jpg", token))
+	require.Equal(t, http.StatusForbidden, do("/info/http://img.example.com/a.jpg", token))
+	require.Equal(t, http.StatusForbidden, do("/fill/400/0/http://img.example.com/a.jpg", token+"x"))
+
+	expired, err := signer.Token(urlsign.Claims{ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, do("/info/http://img.example.com/a.jpg", expired))
+}
@@ -0,0 +1,97 @@
+package urlsign
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidToken is returned when a token is malformed or its signature doesn't match any of the keys.
+var ErrInvalidToken = errors.New("invalid token")
+
+// tokenHeader is the only JWT header accepted and issued.
+const tokenHeader = `{"alg":"HS256","typ":"JWT"}`
+
+// Claims are the claims of a JWT token authorizing requests. Empty restrictions allow everything.
+type Claims struct {
+	// ExpiresAt is the unix timestamp after which the token is rejected. Zero means the token never expires.
+	ExpiresAt int64 `json:"exp,omitempty"`
+	// Origins are the host patterns of the source images, like "example.com" or "*.example.com:8080".
+	Origins []string `json:"origins,omitempty"`
+	// MaxWidth and MaxHeight limit the size of previews.
+	MaxWidth  uint `json:"max_width,omitempty"`
+	MaxHeight uint `json:"max_height,omitempty"`
+	// Operations are the allowed routes named after the first path segment, like "fill" or "info".
+	Operations []string `json:"ops,omitempty"`
+}
+
+// AllowsOperation reports whether the claims allow the operation.
+func (c Claims) AllowsOperation(op string) bool {
+	return len(c.Operations) == 0 || slices.Contains(c.Operations, op)
+}
+
+// Token issues an HS256 JWT token with the claims signed with the first key.
+func (s *Signer) Token(claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.Wrap(err, "[urlsign::Token]: failed to marshal claims")
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString([]byte(tokenHeader)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac(s.keys[0], signed, nil)), nil
+}
+
+// ParseToken verifies an HS256 JWT token with any of the keys and returns its claims.
+// ErrExpired is returned if the token has expired by now.
+func (s *Signer) ParseToken(token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, errors.Wrap(ErrInvalidToken, "[urlsign::ParseToken]: malformed token")
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Claims{}, errors.Wrap(ErrInvalidToken, "[urlsign::ParseToken]: malformed header")
+	}
+
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	// Only HS256 is accepted, so a token can't pick a weaker algorithm like "none".
+	if err = json.Unmarshal(header, &h); err != nil || h.Alg != "HS256" {
+		return Claims{}, errors.Wrap(ErrInvalidToken, "[urlsign::ParseToken]: unsupported header")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, errors.Wrap(ErrInvalidToken, "[urlsign::ParseToken]: malformed signature")
+	}
+
+	signed := parts[0] + "." + parts[1]
+	if !slices.ContainsFunc(s.keys, func(key []byte) bool { return hmac.Equal(sig, mac(key, signed, nil)) }) {
+		return Claims{}, errors.Wrap(ErrInvalidToken, "[urlsign::ParseToken]: signature mismatch")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, errors.Wrap(ErrInvalidToken, "[urlsign::ParseToken]: malformed payload")
+	}
+
+	var claims Claims
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, errors.Wrap(ErrInvalidToken, "[urlsign::ParseToken]: malformed claims")
+	}
+
+	if claims.ExpiresAt != 0 && now.Unix() > claims.ExpiresAt {
+		return Claims{}, errors.Wrapf(ErrExpired, "[urlsign::ParseToken]: at %v", time.Unix(claims.ExpiresAt, 0).UTC())
+	}
+
+	return claims, nil
+}
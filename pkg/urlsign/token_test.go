@@ -0,0 +1,76 @@
+package urlsign
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToken(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	signer := NewSigner([]byte("current"), []byte("previous"))
+
+	claims := Claims{
+		ExpiresAt:  now.Add(time.Hour).Unix(),
+		Origins:    []string{"*.example.com"},
+		MaxWidth:   400,
+		MaxHeight:  300,
+		Operations: []string{"fill"},
+	}
+
+	token, err := signer.Token(claims)
+	require.NoError(t, err)
+
+	parsed, err := signer.ParseToken(token, now)
+	require.NoError(t, err)
+	require.Equal(t, claims, parsed)
+
+	_, err = signer.ParseToken(token, now.Add(2*time.Hour))
+	require.ErrorIs(t, err, ErrExpired)
+
+	t.Run("rotation", func(t *testing.T) {
+		old, err := NewSigner([]byte("previous")).Token(claims)
+		require.NoError(t, err)
+
+		_, err = signer.ParseToken(old, now)
+		require.NoError(t, err)
+
+		other, err := NewSigner([]byte("other")).Token(claims)
+		require.NoError(t, err)
+
+		_, err = signer.ParseToken(other, now)
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+		parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"max_width":4000}`))
+
+		_, err := signer.ParseToken(strings.Join(parts, "."), now)
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("alg none", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+		parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+
+		_, err := signer.ParseToken(parts[0]+"."+parts[1]+".", now)
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, token := range []string{"", "a.b", "a.b.c", "!.!.!"} {
+			_, err := signer.ParseToken(token, now)
+			require.ErrorIs(t, err, ErrInvalidToken, token)
+		}
+	})
+}
+
+func TestClaimsAllowsOperation(t *testing.T) {
+	require.True(t, Claims{}.AllowsOperation("batch"))
+	require.True(t, Claims{Operations: []string{"fill", "info"}}.AllowsOperation("info"))
+	require.False(t, Claims{Operations: []string{"fill", "info"}}.AllowsOperation("batch"))
+}
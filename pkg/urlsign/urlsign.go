@@ -0,0 +1,146 @@
+// Package urlsign signs and verifies image-previewer request URLs.
+//
+// A signed URL carries the signature as the first path segment, followed by the path of the route
+// with the source URL and the query:
+//
+//	/{signature}/fill/300/200/https://example.com/image.jpg?format=png
+//
+// The signature is the unpadded base64url encoded HMAC-SHA256 of everything after the signature
+// segment: the escaped path and the query, if any. Requests with a body also cover it,
+// separated from the path by a newline.
+//
+// The optional exp query parameter is a unix timestamp after which a signed URL is rejected.
+// Being a part of the query, it's covered by the signature.
+//
+// Instead of a signature, a request may carry a token (see Claims) restricting what it's allowed to do.
+package urlsign
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidSignature is returned when the signature doesn't match any of the keys.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// ErrExpired is returned when a signed URL or a token has expired.
+var ErrExpired = errors.New("expired")
+
+// ErrWeakKey is returned when a key is blank or shorter than MinKeySize.
+var ErrWeakKey = errors.New("weak key")
+
+// ExpParam is the query parameter holding the expiration time of a signed URL.
+const ExpParam = "exp"
+
+// MinKeySize is the minimum size of a key in bytes, the size of the HMAC-SHA256 output.
+const MinKeySize = sha256.Size
+
+// CheckKey returns ErrWeakKey if the key can't be used for signing: it's blank or shorter than MinKeySize.
+// Signatures made with an empty key can be computed by anyone.
+func CheckKey(key []byte) error {
+	switch {
+	case len(bytes.TrimSpace(key)) == 0:
+		return errors.Wrap(ErrWeakKey, "[urlsign::CheckKey]: key is blank")
+	case len(key) < MinKeySize:
+		return errors.Wrapf(ErrWeakKey, "[urlsign::CheckKey]: key is %d bytes long, at least %d required", len(key), MinKeySize)
+	default:
+		return nil
+	}
+}
+
+// Signer signs URLs with the first of its keys and verifies them with any of them,
+// which allows rotating keys without invalidating the URLs signed with the previous ones.
+type Signer struct {
+	keys [][]byte
+}
+
+// NewSigner creates a signer with the keys. The first key is used for signing.
+func NewSigner(key []byte, previous ...[]byte) *Signer {
+	return &Signer{keys: append([][]byte{key}, previous...)}
+}
+
+// Sign returns the path prefixed with its signature segment. The path must be escaped
+// and may contain a query.
+func (s *Signer) Sign(path string) string {
+	return "/" + s.Signature(path, nil) + path
+}
+
+// SignWithExpiry returns the path with the expiration time added to its query, prefixed with its signature segment.
+func (s *Signer) SignWithExpiry(path string, exp time.Time) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	return s.Sign(path + sep + ExpParam + "=" + strconv.FormatInt(exp.Unix(), 10))
+}
+
+// CheckExpiry returns ErrExpired if the query of a signed URL has an expiration time before now.
+// Queries without the expiration time never expire.
+func CheckExpiry(query url.Values, now time.Time) error {
+	if !query.Has(ExpParam) {
+		return nil
+	}
+
+	exp, err := strconv.ParseInt(query.Get(ExpParam), 10, 64)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidSignature, "[urlsign::CheckExpiry]: invalid %s %q", ExpParam, query.Get(ExpParam))
+	}
+
+	if now.Unix() > exp {
+		return errors.Wrapf(ErrExpired, "[urlsign::CheckExpiry]: at %v", time.Unix(exp, 0).UTC())
+	}
+
+	return nil
+}
+
+// Signature returns the signature of the escaped path with an optional query and of the request body.
+func (s *Signer) Signature(path string, body []byte) string {
+	return base64.RawURLEncoding.EncodeToString(mac(s.keys[0], path, body))
+}
+
+// Verify checks the signature of the escaped path with an optional query and of the request body.
+func (s *Signer) Verify(sig, path string, body []byte) error {
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return errors.Wrap(ErrInvalidSignature, "[urlsign::Verify]: malformed signature")
+	}
+
+	for _, key := range s.keys {
+		if hmac.Equal(got, mac(key, path, body)) {
+			return nil
+		}
+	}
+
+	return errors.Wrap(ErrInvalidSignature, "[urlsign::Verify]")
+}
+
+// Split splits a signed escaped path into the signature and the signed path.
+func Split(signedPath string) (string, string, error) {
+	sig, path, ok := strings.Cut(strings.TrimPrefix(signedPath, "/"), "/")
+	if !ok || sig == "" {
+		return "", "", errors.Wrap(ErrInvalidSignature, "[urlsign::Split]: missing signature segment")
+	}
+
+	return sig, "/" + path, nil
+}
+
+func mac(key []byte, path string, body []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(path))
+
+	if len(body) > 0 {
+		h.Write([]byte("\n"))
+		h.Write(body)
+	}
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,76 @@
+package urlsign
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	path := "/fill/300/200/https://example.com/image.jpg?format=png"
+
+	signer := NewSigner([]byte("current"), []byte("previous"))
+	old := NewSigner([]byte("previous"))
+	other := NewSigner([]byte("other"))
+
+	signed := signer.Sign(path)
+	sig, rest, err := Split(signed)
+	require.NoError(t, err)
+	require.Equal(t, path, rest)
+	require.Len(t, sig, 43)
+
+	require.NoError(t, signer.Verify(sig, path, nil))
+	require.ErrorIs(t, signer.Verify(sig, path+"&quality=10", nil), ErrInvalidSignature)
+	require.ErrorIs(t, signer.Verify(sig, path, []byte("body")), ErrInvalidSignature)
+	require.ErrorIs(t, other.Verify(sig, path, nil), ErrInvalidSignature)
+	require.ErrorIs(t, signer.Verify("not base64!", path, nil), ErrInvalidSignature)
+
+	// URLs signed with a previous key stay valid during rotation.
+	require.NoError(t, signer.Verify(old.Signature(path, nil), path, nil))
+	require.ErrorIs(t, old.Verify(sig, path, nil), ErrInvalidSignature)
+
+	body := []byte(`{"url":"https://example.com/image.jpg"}`)
+	require.NoError(t, signer.Verify(signer.Signature("/batch", body), "/batch", body))
+}
+
+func TestSplit(t *testing.T) {
+	for _, path := range []string{"", "/", "/signature", "//fill/1/1/x"} {
+		_, _, err := Split(path)
+		require.ErrorIs(t, err, ErrInvalidSignature, path)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	signer := NewSigner([]byte("key"))
+	now := time.Unix(1_700_000_000, 0)
+
+	signed := signer.SignWithExpiry("/fill/300/200/https://example.com/image.jpg?format=png", now.Add(time.Hour))
+	require.Contains(t, signed, "?format=png&exp=1700003600")
+
+	sig, path, err := Split(signed)
+	require.NoError(t, err)
+	require.NoError(t, signer.Verify(sig, path, nil))
+
+	u, err := url.Parse(path)
+	require.NoError(t, err)
+	require.NoError(t, CheckExpiry(u.Query(), now))
+	require.NoError(t, CheckExpiry(u.Query(), now.Add(time.Hour)))
+	require.ErrorIs(t, CheckExpiry(u.Query(), now.Add(time.Hour+time.Second)), ErrExpired)
+
+	// Extending the expiration time invalidates the signature.
+	require.ErrorIs(t, signer.Verify(sig, strings.Replace(path, "1700003600", "1800000000", 1), nil), ErrInvalidSignature)
+
+	require.NoError(t, CheckExpiry(url.Values{}, now))
+	require.ErrorIs(t, CheckExpiry(url.Values{ExpParam: {"soon"}}, now), ErrInvalidSignature)
+}
+
+func TestCheckKey(t *testing.T) {
+	require.NoError(t, CheckKey(bytes.Repeat([]byte("k"), MinKeySize)))
+	require.ErrorIs(t, CheckKey(nil), ErrWeakKey)
+	require.ErrorIs(t, CheckKey([]byte(strings.Repeat(" ", MinKeySize))), ErrWeakKey)
+	require.ErrorIs(t, CheckKey([]byte("short")), ErrWeakKey)
+}
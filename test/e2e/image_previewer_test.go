@@ -3,10 +3,13 @@ package e2e
 import (
 	"bytes"
 	_ "embed"
+	"encoding/json"
 	"fmt"
 	"image"
 	_ "image/jpeg"
+	_ "image/png"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -122,6 +125,13 @@ func TestNegative(t *testing.T) {
 			imgURL: fmt.Sprintf(imgTemplate, "gopher_2000x1000.jpg"),
 			status: http.StatusBadRequest,
 		},
+		{
+			name:   "size exceeds the limits",
+			width:  4000000000,
+			height: 4000000000,
+			imgURL: fmt.Sprintf(imgTemplate, "gopher_2000x1000.jpg"),
+			status: http.StatusBadRequest,
+		},
 		{
 			name:   "link is not a valid url",
 			width:  100,
@@ -134,21 +144,21 @@ func TestNegative(t *testing.T) {
 			width:  100,
 			height: 100,
 			imgURL: fmt.Sprintf(imgTemplate, "gopher_1000x2000.jpg"),
-			status: http.StatusBadGateway,
+			status: http.StatusNotFound,
 		},
 		{
-			name:   "server not found",
+			name:   "loopback address is blocked",
 			width:  100,
 			height: 100,
 			imgURL: "http://localhost/gopher_1000x1000.jpg",
-			status: http.StatusBadGateway,
+			status: http.StatusForbidden,
 		},
 		{
 			name:   "not an image file",
 			width:  100,
 			height: 100,
 			imgURL: fmt.Sprintf(imgTemplate, "gopher.txt"),
-			status: http.StatusBadGateway,
+			status: http.StatusUnsupportedMediaType,
 		},
 	}
 
@@ -167,3 +177,80 @@ func TestNegative(t *testing.T) {
 		})
 	}
 }
+
+func TestImageInfo(t *testing.T) {
+	reqURL := fmt.Sprintf("http://localhost:8081/info/%v", fmt.Sprintf(imgTemplate, "gopher_1000x500.jpg"))
+
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var info struct {
+		Width    int    `json:"width"`
+		Height   int    `json:"height"`
+		Format   string `json:"format"`
+		ByteSize int    `json:"byte_size"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+	require.Equal(t, 1000, info.Width)
+	require.Equal(t, 500, info.Height)
+	require.Equal(t, "jpeg", info.Format)
+	require.Equal(t, len(gopher1000x500), info.ByteSize)
+}
+
+func TestBatch(t *testing.T) {
+	body := fmt.Sprintf(`{"url":%q,"renditions":[{"width":200,"height":200},{"width":1000,"height":500,"format":"png"}]}`,
+		fmt.Sprintf(imgTemplate, "gopher_2000x1000.jpg"))
+
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8081/batch", strings.NewReader(body))
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var manifest struct {
+		Renditions []struct {
+			Format string `json:"format"`
+			Data   []byte `json:"data"`
+		} `json:"renditions"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&manifest))
+	require.Len(t, manifest.Renditions, 2)
+
+	for i, expected := range []struct {
+		format string
+		bounds image.Rectangle
+	}{
+		{format: "jpeg", bounds: image.Rect(0, 0, 200, 200)},
+		{format: "png", bounds: image.Rect(0, 0, 1000, 500)},
+	} {
+		img, format, err := image.Decode(bytes.NewReader(manifest.Renditions[i].Data))
+		require.NoError(t, err)
+		require.Equal(t, expected.format, format)
+		require.Equal(t, expected.bounds, img.Bounds())
+	}
+}
+
+func TestDummyImage(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://localhost:8081/dummy/320/240?bg=eeeeee&text=hello", nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	img, format, err := image.Decode(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "png", format)
+	require.Equal(t, image.Rect(0, 0, 320, 240), img.Bounds())
+}